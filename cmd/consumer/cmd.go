@@ -1,17 +1,29 @@
 package consumer
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/hhr0815hhr/gint/internal"
 	"github.com/hhr0815hhr/gint/internal/cache"
 	"github.com/hhr0815hhr/gint/internal/config"
 	"github.com/hhr0815hhr/gint/internal/goroutines"
 	"github.com/hhr0815hhr/gint/internal/log"
 	"github.com/hhr0815hhr/gint/internal/pkg/i18n"
-	"github.com/hhr0815hhr/gint/internal/queue/memory_queue"
-	"github.com/hhr0815hhr/gint/internal/queue/redis_queue"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/drivers"
+	"github.com/hhr0815hhr/gint/internal/queue/middleware"
 	"github.com/spf13/cobra"
 )
 
+var (
+	concurrency int
+	queues      []string
+)
+
 var ConsumeCmd = &cobra.Command{
 	Use:   "consumer",
 	Short: "Start consumers",
@@ -22,24 +34,127 @@ var ConsumeCmd = &cobra.Command{
 	},
 }
 
+func init() {
+	ConsumeCmd.Flags().IntVar(&concurrency, "concurrency", 0, "每个队列的并发处理数，覆盖配置文件")
+	ConsumeCmd.Flags().StringSliceVar(&queues, "queues", nil, "只启动指定队列的消费者，多个队列用逗号分隔")
+}
+
 func initQueue() {
-	switch config.Conf.Server.Queue {
-	case "memory":
-		internal.App.Data["queue"] = memory_queue.NewInMemoryDriver()
-	case "redis":
-		internal.App.Data["queue"] = redis_queue.NewRedisListDriver(cache.Client, config.Conf.Redis.Type)
-	default:
-		log.Logger.Fatalf("unknown queue driver: %s", config.Conf.Server.Queue)
+	driver, err := drivers.New(config.Conf.Server.Queue)
+	if err != nil {
+		log.Logger.Fatal(err)
+	}
+	internal.App.Data["queue"] = driver
+	queue.SetDriver(driver)
+	broadcaster, err := drivers.NewBroadcaster(config.Conf.Server.Queue, driver)
+	if err != nil {
+		log.Logger.Fatal(err)
 	}
+	queue.SetBroadcaster(broadcaster)
 	log.Logger.Println("初始化队列...success")
 }
+
+// initConcurrency 设置各队列 worker pool 的大小，命令行参数优先于配置文件
+func initConcurrency() {
+	if concurrency > 0 {
+		queue.SetDefaultConcurrency(concurrency)
+		return
+	}
+	queue.SetDefaultConcurrency(config.Conf.Queue.Concurrency)
+	for name, n := range config.Conf.Queue.QueueConcurrency {
+		queue.SetConcurrency(name, n)
+	}
+}
+
+// initRetry 根据配置设置重试策略，未配置的字段使用默认值
+func initRetry() {
+	queue.SetDefaultRetryPolicy(retryPolicy(config.Conf.Queue.Retry, queue.DefaultRetryPolicy))
+	for msgType, r := range config.Conf.Queue.MsgRetry {
+		queue.SetRetryPolicy(msgType, retryPolicy(r, queue.DefaultRetryPolicy))
+	}
+}
+
+func retryPolicy(r config.Retry, base queue.RetryPolicy) queue.RetryPolicy {
+	if r.MaxAttempts > 0 {
+		base.MaxAttempts = r.MaxAttempts
+	}
+	if r.BaseDelay > 0 {
+		base.BaseDelay = time.Duration(r.BaseDelay) * time.Second
+	}
+	if r.MaxDelay > 0 {
+		base.MaxDelay = time.Duration(r.MaxDelay) * time.Second
+	}
+	if r.Jitter > 0 {
+		base.Jitter = r.Jitter
+	}
+	return base
+}
+
+// initDedup 开启消费端去重，跳过已经处理成功的重复消息
+func initDedup() {
+	if !config.Conf.Queue.Dedup {
+		return
+	}
+	ttl := time.Duration(config.Conf.Queue.DedupTTL) * time.Second
+	if ttl <= 0 {
+		ttl = queue.DefaultDedupTTL
+	}
+	store, err := drivers.NewDedupStore(config.Conf.Server.Queue, internal.App.Data["queue"].(queue.Driver), ttl)
+	if err != nil {
+		log.Logger.Fatal(err)
+	}
+	queue.SetDedupStore(store, ttl)
+}
+
+// initMiddleware 注册默认的消费端中间件，逐条消费和批量消费使用相同的中间件
+func initMiddleware() {
+	timeout := time.Duration(config.Conf.Queue.HandlerTimeout) * time.Second
+	queue.Use(
+		middleware.Recover(),
+		middleware.Logging(),
+		middleware.Metrics(),
+		middleware.Timeout(timeout),
+	)
+	queue.UseBatch(
+		middleware.RecoverBatch(),
+		middleware.LoggingBatch(),
+		middleware.MetricsBatch(),
+		middleware.TimeoutBatch(timeout),
+	)
+}
+
 func doInit() {
 	i18n.InitI18n()
 	internal.App = internal.InitApp()
 	internal.App.Data["cache"] = cache.InitializeCache()
 	initQueue()
+	initConcurrency()
+	initRetry()
+	queue.SetStarvationEvery(config.Conf.Queue.StarvationEvery)
+	initDedup()
+	initMiddleware()
 }
 
+// startConsumer 收到 SIGINT/SIGTERM 后停止拉取，排空处理中的消息后关闭队列驱动，再次收到信号时直接退出
 func startConsumer() {
-	goroutines.RunGlobalGoroutines()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// 恢复默认的信号处理，排空期间再次 Ctrl+C 可以强制退出
+		stop()
+		log.Logger.Println("收到退出信号，开始优雅退出...")
+	}()
+
+	if err := queue.StartSubscribers(ctx); err != nil {
+		log.Logger.Fatal(err)
+	}
+	err := goroutines.RunGlobalGoroutines(ctx, queues)
+	if cerr := internal.App.Data["queue"].(queue.Driver).Close(); cerr != nil {
+		log.Logger.Errorf("Close queue driver failed: %v", cerr)
+	}
+	if err != nil {
+		log.Logger.Fatalf("Error consuming: %v", err)
+	}
+	log.Logger.Println("消费者已退出")
 }
@@ -1,6 +1,12 @@
 package cron
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/hhr0815hhr/gint/internal"
 	"github.com/hhr0815hhr/gint/internal/cache"
 	cron2 "github.com/hhr0815hhr/gint/internal/cron"
@@ -8,10 +14,21 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const stopTimeout = 30 * time.Second // 退出时等待正在执行的任务结束的时间
+
 var CronCmd = &cobra.Command{
 	Use:   "cron",
 	Short: "Start cron jobs",
-	Long:  `Start cron jobs`,
+	Long:  `Start cron jobs, or list and run registered jobs by subcommands`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// 不带子命令时与 start 相同
+		startCmd.Run(cmd, args)
+	},
+}
+
+var startCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the cron scheduler",
 	Run: func(cmd *cobra.Command, args []string) {
 		//初始化rcon配置
 		doInit()
@@ -19,16 +36,40 @@ var CronCmd = &cobra.Command{
 	},
 }
 
+func init() {
+	CronCmd.AddCommand(startCmd, listCmd, runCmd)
+	// 清理过期的执行记录，保留时间见 config.Conf.Cron.Retention
+	cron2.Register(cron2.Job{Name: "prune_cron_runs", Spec: cron2.CronHourly, Lock: true, Func: func(ctx context.Context) error {
+		return internal.App.CronLogic.Prune(ctx)
+	}})
+}
+
 func doInit() {
 	internal.App = internal.InitApp()
 	internal.App.Data["cache"] = cache.InitializeCache()
+	cron2.SetSource(internal.App.CronLogic.Source())
+}
+
+// newCronJob 创建带任务锁、执行记录和调度状态的调度器
+func newCronJob() *cron2.CronJob {
+	locker := cron2.NewLocker(cache.Client, cron2.LockConfigOptions()...)
+	log.Logger.Infof("cron node id: %s", locker.NodeId())
+	opts := append(cron2.ConfigOptions(), cron2.WithLocker(locker), cron2.WithRecorder(internal.App.CronLogic), cron2.WithStatus(cache.Client))
+	return cron2.New(opts...)
 }
 
 func startCronJob() {
 	log.Logger.Info("starting cron jobs...")
 
-	c := cron2.New()
-	c.AddFuncs()
+	c := newCronJob()
+	c.AddJobs()
 	c.Start()
-	select {}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Logger.Info("stopping cron jobs...")
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	c.Stop(ctx)
 }
@@ -0,0 +1,57 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/cache"
+	cron2 "github.com/hhr0815hhr/gint/internal/cron"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered cron jobs",
+	Long:  `List registered cron jobs with their effective spec and next run time`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// 加载调度设置，列出实际生效的 Spec
+		doInit()
+		fmt.Printf("%-24s  %-20s  %-25s  %-8s  %-7s  %s\n", "NAME", "SPEC", "NEXT", "TIMEOUT", "OVERLAP", "LOCK")
+		for _, s := range cron2.Schedules(context.Background(), cache.Client) {
+			timeout, next := "-", "disabled"
+			if s.Job.Timeout > 0 {
+				timeout = s.Job.Timeout.String()
+			}
+			if !s.Disabled {
+				next = s.Next.Format(time.RFC3339)
+			}
+			fmt.Printf("%-24s  %-20s  %-25s  %-8s  %-7s  %t\n",
+				s.Job.Name, s.Job.Spec, next, timeout, s.Job.Overlap, s.Job.Lock)
+		}
+	},
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run a cron job once in the foreground",
+	Long:  `Run a cron job once in the foreground, ignoring its overlap policy and lock, and exit with a non-zero status if it fails`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, ok := cron2.Lookup(args[0]); !ok {
+			cobra.CheckErr(fmt.Errorf("cron job %s is not registered, see `cron list`", args[0]))
+		}
+		doInit()
+
+		// 中断时取消任务的 ctx，任务返回后照常保存执行记录
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		run, err := newCronJob().RunNow(ctx, args[0])
+		stop()
+		cobra.CheckErr(err)
+		fmt.Printf("%s %s in %s\n", run.Job, run.Status, run.Duration())
+	},
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/hhr0815hhr/gint/cmd/consumer"
 	"github.com/hhr0815hhr/gint/cmd/cron"
 	"github.com/hhr0815hhr/gint/cmd/gen"
+	"github.com/hhr0815hhr/gint/cmd/queue"
 	"github.com/hhr0815hhr/gint/cmd/server"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +23,7 @@ func init() {
 	rootCmd.AddCommand(gen.GenCmd)
 	rootCmd.AddCommand(consumer.ConsumeCmd)
 	rootCmd.AddCommand(cron.CronCmd)
+	rootCmd.AddCommand(queue.QueueCmd)
 }
 
 func main() {
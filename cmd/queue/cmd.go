@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"github.com/hhr0815hhr/gint/internal"
+	"github.com/hhr0815hhr/gint/internal/cache"
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/log"
+	queue2 "github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/drivers"
+	"github.com/spf13/cobra"
+)
+
+var QueueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "队列管理",
+	Long:  `队列管理工具`,
+}
+
+func init() {
+	QueueCmd.AddCommand(dlqCmd)
+}
+
+func doInit() {
+	internal.App = internal.InitApp()
+	internal.App.Data["cache"] = cache.InitializeCache()
+	// 内存队列只存在于消费进程中，独立的管理进程看到的是空队列，持久化目录也被运行中的消费进程锁定
+	if config.Conf.Server.Queue == drivers.DriverMemory {
+		log.Logger.Fatalf("queue driver %s keeps messages inside the consumer process and cannot be managed from another process", drivers.DriverMemory)
+	}
+	driver, err := drivers.New(config.Conf.Server.Queue)
+	if err != nil {
+		log.Logger.Fatal(err)
+	}
+	internal.App.Data["queue"] = driver
+	queue2.SetDriver(driver)
+}
+
+// driver 当前配置的队列驱动
+func driver() queue2.Driver {
+	return internal.App.Data["queue"].(queue2.Driver)
+}
+
+// inspector 当前驱动需要实现 queue.Inspector 才能查看队列
+func inspector() queue2.Inspector {
+	insp, ok := driver().(queue2.Inspector)
+	if !ok {
+		log.Logger.Fatalf("queue driver %s does not support inspection", config.Conf.Server.Queue)
+	}
+	return insp
+}
@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	queue2 "github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dlqName     string
+	listType    string
+	listPage    int64
+	listLimit   int64
+	replayType  string
+	replayId    string
+	replayTo    string
+	replayLimit int64
+	purgeType   string
+)
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "死信队列管理",
+	Long:  `查看、重放和清空死信队列中的消息`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		doInit()
+	},
+}
+
+var dlqListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "分页查看死信消息",
+	Run: func(cmd *cobra.Command, args []string) {
+		if listPage < 1 {
+			listPage = 1
+		}
+		if listLimit <= 0 {
+			listLimit = 20
+		}
+		ctx := context.Background()
+		insp := inspector()
+		total, err := insp.Len(ctx, dlqName)
+		cobra.CheckErr(err)
+
+		var (
+			offset = (listPage - 1) * listLimit
+			skip   int64
+			msgs   []*queue2.Message
+		)
+		err = queue2.Scan(ctx, insp, dlqName, typeFilter(listType), func(m *queue2.Message) bool {
+			if skip < offset {
+				skip++
+				return true
+			}
+			msgs = append(msgs, m)
+			return int64(len(msgs)) < listLimit
+		})
+		cobra.CheckErr(err)
+
+		fmt.Printf("queue: %s, total: %d, page: %d\n", dlqName, total, listPage)
+		fmt.Printf("%-32s  %-16s  %-5s  %-16s  %-25s  %s\n", "ID", "TYPE", "RETRY", "ORIGINAL QUEUE", "FAILED AT", "LAST ERROR")
+		for _, m := range msgs {
+			fmt.Printf("%-32s  %-16s  %-5d  %-16s  %-25s  %s\n",
+				m.Id, m.MsgType, m.ReInCount,
+				header(m, queue2.HeaderOriginalQueue), header(m, queue2.HeaderFailedAt), header(m, queue2.HeaderLastError))
+		}
+	},
+}
+
+var dlqShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "查看死信消息详情",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := queue2.Find(context.Background(), inspector(), dlqName, args[0])
+		cobra.CheckErr(err)
+		b, _ := json.MarshalIndent(m, "", "  ")
+		fmt.Println(string(b))
+	},
+}
+
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "重新投递死信消息到原队列",
+	Long:  `将死信消息重新投递到原队列 (或 --to 指定的队列)，并重置重试次数`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		insp := inspector()
+
+		// 先收集再重放，避免边遍历边删除导致分页错位
+		var msgs []*queue2.Message
+		filter := typeFilter(replayType)
+		err := queue2.Scan(ctx, insp, dlqName, func(m *queue2.Message) bool {
+			return (replayId == "" || m.Id == replayId) && (filter == nil || filter(m))
+		}, func(m *queue2.Message) bool {
+			msgs = append(msgs, m)
+			return replayLimit <= 0 || int64(len(msgs)) < replayLimit
+		})
+		cobra.CheckErr(err)
+
+		var replayed int
+		for _, m := range msgs {
+			if queue2.IsUndecodable(m) {
+				fmt.Printf("skip undecodable message %s, fix or remove it manually\n", m.Id)
+				continue
+			}
+			target := replayTo
+			if target == "" {
+				target = header(m, queue2.HeaderOriginalQueue)
+			}
+			if target == "" {
+				target = queue2.DefaultQueueName
+			}
+			m.ReInCount = 0
+			delete(m.Headers, queue2.HeaderLastError)
+			delete(m.Headers, queue2.HeaderFailedAt)
+			delete(m.Headers, queue2.HeaderOriginalQueue)
+			// 先投递再删除，失败时消息仍保留在死信队列中
+			if err = driver().Publish(ctx, target, m); err != nil {
+				fmt.Printf("replay %s to %s failed: %v\n", m.Id, target, err)
+				continue
+			}
+			if err = insp.Remove(ctx, dlqName, m.Id); err != nil {
+				fmt.Printf("remove %s from %s failed: %v\n", m.Id, dlqName, err)
+			}
+			replayed++
+		}
+		fmt.Printf("replayed %d messages\n", replayed)
+	},
+}
+
+var dlqPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "清空死信队列",
+	Long:  `清空死信队列，指定 --type 时只删除该类型的消息`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		insp := inspector()
+		if purgeType == "" {
+			n, err := insp.Purge(ctx, dlqName)
+			cobra.CheckErr(err)
+			fmt.Printf("purged %d messages\n", n)
+			return
+		}
+
+		var ids []string
+		err := queue2.Scan(ctx, insp, dlqName, typeFilter(purgeType), func(m *queue2.Message) bool {
+			ids = append(ids, m.Id)
+			return true
+		})
+		cobra.CheckErr(err)
+		var purged int
+		for _, id := range ids {
+			if err = insp.Remove(ctx, dlqName, id); err != nil {
+				fmt.Printf("remove %s failed: %v\n", id, err)
+				continue
+			}
+			purged++
+		}
+		fmt.Printf("purged %d messages\n", purged)
+	},
+}
+
+func init() {
+	dlqCmd.PersistentFlags().StringVar(&dlqName, "queue", queue2.DeadQueueName, "死信队列名称")
+
+	dlqListCmd.Flags().StringVar(&listType, "type", "", "按消息类型过滤")
+	dlqListCmd.Flags().Int64Var(&listPage, "page", 1, "页码")
+	dlqListCmd.Flags().Int64Var(&listLimit, "limit", 20, "每页数量")
+
+	dlqReplayCmd.Flags().StringVar(&replayType, "type", "", "按消息类型过滤")
+	dlqReplayCmd.Flags().StringVar(&replayId, "id", "", "只重放指定 Id 的消息")
+	dlqReplayCmd.Flags().StringVar(&replayTo, "to", "", "投递到指定队列，默认为消息的原队列")
+	dlqReplayCmd.Flags().Int64Var(&replayLimit, "limit", 0, "最多重放的消息数，0 表示不限制")
+
+	dlqPurgeCmd.Flags().StringVar(&purgeType, "type", "", "只删除指定类型的消息")
+
+	dlqCmd.AddCommand(dlqListCmd, dlqShowCmd, dlqReplayCmd, dlqPurgeCmd)
+}
+
+func typeFilter(msgType string) func(*queue2.Message) bool {
+	if msgType == "" {
+		return nil
+	}
+	return func(m *queue2.Message) bool {
+		return m.MsgType == msgType
+	}
+}
+
+func header(m *queue2.Message, key string) string {
+	if v, ok := m.Headers[key]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
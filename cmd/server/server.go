@@ -12,28 +12,34 @@ import (
 	"github.com/hhr0815hhr/gint/internal"
 	"github.com/hhr0815hhr/gint/internal/cache"
 	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/cron"
 	"github.com/hhr0815hhr/gint/internal/log"
 	"github.com/hhr0815hhr/gint/internal/pkg/i18n"
-	"github.com/hhr0815hhr/gint/internal/queue/memory_queue"
-	"github.com/hhr0815hhr/gint/internal/queue/redis_queue"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/drivers"
 )
 
 func doInit() {
 	i18n.InitI18n()
 	internal.App = internal.InitApp()
 	internal.App.Data["cache"] = cache.InitializeCache()
+	// 管理接口展示按调度设置覆盖后的 Spec
+	cron.SetSource(internal.App.CronLogic.Source())
 	initQueue()
 }
 
 func initQueue() {
-	switch config.Conf.Server.Queue {
-	case "memory":
-		internal.App.Data["queue"] = memory_queue.NewInMemoryDriver()
-	case "redis":
-		internal.App.Data["queue"] = redis_queue.NewRedisListDriver(cache.Client, config.Conf.Redis.Type)
-	default:
-		log.Logger.Fatalf("unknown queue driver: %s", config.Conf.Server.Queue)
+	driver, err := drivers.New(config.Conf.Server.Queue)
+	if err != nil {
+		log.Logger.Fatal(err)
 	}
+	internal.App.Data["queue"] = driver
+	queue.SetDriver(driver)
+	broadcaster, err := drivers.NewBroadcaster(config.Conf.Server.Queue, driver)
+	if err != nil {
+		log.Logger.Fatal(err)
+	}
+	queue.SetBroadcaster(broadcaster)
 	log.Logger.Println("初始化队列...success")
 }
 
@@ -47,12 +53,20 @@ func start() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	// 各副本接收缓存失效、配置变更等广播
+	subCtx, stopSubscribers := context.WithCancel(context.Background())
+	defer stopSubscribers()
+	if err := queue.StartSubscribers(subCtx); err != nil {
+		log.Logger.Fatal(err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	go func() {
 		<-quit
 		log.Logger.Println("Shutting down server...")
+		stopSubscribers()
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Logger.Fatalf("Server forced to shutdown: %v", err)
 		}
@@ -60,6 +74,6 @@ func start() {
 	}()
 	log.Logger.Printf("Starting server on :%d", port)
 	if err := srv.ListenAndServe(); err != nil {
-		log.Logger.Fatalf(err.Error())
+		log.Logger.Fatal(err)
 	}
 }
@@ -46,14 +46,91 @@ type AirWallex struct {
 type Server struct {
 	Env       string    `yaml:"env"`
 	Port      int       `yaml:"port"`
-	Queue     string    `yaml:"queue"`
+	Queue     string    `yaml:"queue"` // 队列驱动: memory / redis / redis_stream / mysql / nats
 	Google    Google    `yaml:"google"`
 	Mail      Mail      `yaml:"mail"`
 	AirWallex AirWallex `yaml:"airwallex"`
 }
 
+type Queue struct {
+	Reliable          bool   `yaml:"reliable"`          // redis list 是否开启可靠投递(ack + 可见性超时)
+	ConsumerId        string `yaml:"consumerId"`        // 消费者标识，默认 hostname-pid，redis 可靠模式下每个消费者使用自己的 processing 列表
+	VisibilityTimeout int    `yaml:"visibilityTimeout"` // 消息处理超时时间(秒)，超时后重新入队
+	PollInterval      int    `yaml:"pollInterval"`      // mysql 驱动队列为空时的轮询间隔(毫秒)，默认 1000
+	ReapInterval      int    `yaml:"reapInterval"`      // 回收超时消息的间隔(秒)
+	Group             string `yaml:"group"`             // redis stream 消费者组名称
+	StreamMaxLen      int64  `yaml:"streamMaxLen"`      // redis stream 最大长度(近似裁剪)，0 表示不裁剪
+	Concurrency       int    `yaml:"concurrency"`       // 每个队列默认的并发处理数
+	HandlerTimeout    int    `yaml:"handlerTimeout"`    // 单条消息的处理超时时间(秒)，0 表示不限制
+	DrainTimeout      int    `yaml:"drainTimeout"`      // 退出时等待处理中消息完成的时间(秒)，默认 30
+
+	QueueConcurrency map[string]int   `yaml:"queueConcurrency"` // 按队列单独设置并发处理数
+	Retry            Retry            `yaml:"retry"`            // 默认重试策略
+	MsgRetry         map[string]Retry `yaml:"msgRetry"`         // 按消息类型单独设置重试策略
+	Topics           []Topic          `yaml:"topics"`           // 需要消费的队列，为空时只消费 default 队列
+	StarvationEvery  int              `yaml:"starvationEvery"`  // 每拉取 n 次消息优先拉取一次低优先级消息，默认 10，小于 0 关闭
+	Dedup            bool             `yaml:"dedup"`            // 是否开启消费端去重
+	DedupTTL         int              `yaml:"dedupTTL"`         // 去重记录的保留时间(秒)，默认 24 小时
+	Outbox           Outbox           `yaml:"outbox"`           // 事务发件箱
+	Memory           MemoryQueue      `yaml:"memory"`           // 内存队列驱动
+	Nats             NatsQueue        `yaml:"nats"`             // NATS JetStream 队列驱动
+}
+
+type NatsQueue struct {
+	Url      string `yaml:"url"`      // NATS 服务地址，不使用内嵌 nats-server 时必填
+	Embedded bool   `yaml:"embedded"` // 在进程内启动只接受进程内连接的 nats-server，消息只在当前进程可见，仅用于本地开发
+	StoreDir string `yaml:"storeDir"` // 内嵌 nats-server 的 JetStream 数据目录
+}
+
+type MemoryQueue struct {
+	BufferSize      int    `yaml:"bufferSize"`      // 每个队列最多缓存的消息数，默认 100
+	Overflow        string `yaml:"overflow"`        // 队列已满时的策略 block / drop_oldest / reject，默认 block
+	Dir             string `yaml:"dir"`             // 持久化日志目录，为空时不持久化，同一目录只能由一个进程使用
+	CompactInterval int    `yaml:"compactInterval"` // 压缩日志的间隔(秒)，默认 60
+}
+
+type Outbox struct {
+	Enable      bool `yaml:"enable"`      // 是否在消费进程中启动 relay
+	Interval    int  `yaml:"interval"`    // 扫描发件箱的间隔(秒)，默认 1
+	BatchSize   int  `yaml:"batchSize"`   // 每次投递的最大条数，默认 100
+	MaxAttempts int  `yaml:"maxAttempts"` // 最大投递次数，超过后标记为失败，默认 10
+	Retention   int  `yaml:"retention"`   // 已投递记录的保留时间(秒)，默认 7 天
+}
+
+type Topic struct {
+	Name      string   `yaml:"name"`      // 队列名称
+	Consumers int      `yaml:"consumers"` // 消费者数量，默认 1
+	MsgTypes  []string `yaml:"msgTypes"`  // 队列接受的消息类型，为空表示不限制
+	BatchSize int      `yaml:"batchSize"` // 大于 1 时开启批量消费，每批最多的消息数
+	BatchWait int      `yaml:"batchWait"` // 取到第一条消息后凑批的最长等待时间(毫秒)
+}
+
+type Retry struct {
+	MaxAttempts int     `yaml:"maxAttempts"` // 最大处理次数(包含第一次)
+	BaseDelay   int     `yaml:"baseDelay"`   // 第一次重试的延迟(秒)，之后每次翻倍
+	MaxDelay    int     `yaml:"maxDelay"`    // 重试延迟的上限(秒)
+	Jitter      float64 `yaml:"jitter"`      // 随机抖动比例 0~1
+}
+
+type Cron struct {
+	NodeId         string    `yaml:"nodeId"`         // 当前节点标识，记录在任务锁中，默认 hostname-pid
+	LockTTL        int       `yaml:"lockTTL"`        // 任务锁的租约时间(秒)，默认 60，执行期间自动续约
+	Source         string    `yaml:"source"`         // 任务调度设置的来源 config / db，默认 config
+	ReloadInterval int       `yaml:"reloadInterval"` // 重新加载调度设置的间隔(秒)，默认 30，小于 0 关闭
+	Retention      int       `yaml:"retention"`      // 执行记录的保留时间(秒)，默认 7 天，小于 0 不清理
+	Jobs           []CronJob `yaml:"jobs"`           // source 为 config 时按任务名称覆盖调度设置
+}
+
+type CronJob struct {
+	Name     string `yaml:"name"`     // 注册的任务名称
+	Spec     string `yaml:"spec"`     // cron 表达式，为空时使用注册时的 Spec
+	Disabled bool   `yaml:"disabled"` // 停止调度该任务
+}
+
 type Config struct {
 	Database Database `yaml:"database"`
 	Redis    Redis    `yaml:"redis"`
 	Server   Server   `yaml:"server"`
+	Queue    Queue    `yaml:"queue"`
+	Cron     Cron     `yaml:"cron"`
 }
@@ -6,7 +6,7 @@ import (
 )
 
 var (
-	configFiles = []string{"database", "redis", "server"}
+	configFiles = []string{"database", "redis", "server", "queue", "cron"}
 	Conf        = &Config{}
 )
 
@@ -30,3 +30,20 @@ func init() {
 	}
 	log.Logger.Println("初始化配置文件...success")
 }
+
+// ReadCron 重新读取 cron 配置文件，修改调度设置后不需要重启进程
+func ReadCron() (Cron, error) {
+	v := viper.New()
+	v.SetConfigName("cron")
+	v.SetConfigType("yaml")
+	v.AddConfigPath("./config")
+	conf := &Config{}
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return conf.Cron, nil
+		}
+		return conf.Cron, err
+	}
+	err := v.Unmarshal(conf)
+	return conf.Cron, err
+}
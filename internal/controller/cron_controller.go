@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hhr0815hhr/gint/internal/cron"
+	"github.com/hhr0815hhr/gint/internal/logic"
+	"github.com/hhr0815hhr/gint/internal/middleware"
+	"github.com/hhr0815hhr/gint/internal/pkg/response"
+	"github.com/hhr0815hhr/gint/internal/util"
+)
+
+const (
+	defaultRecentRuns = 10  // 任务列表中每个任务返回的执行记录数
+	maxRunsLimit      = 100 // 分页查询执行记录时每页的最大数量
+)
+
+type CronController struct {
+	cronLogic *logic.CronLogic
+}
+
+func NewCronController(cronLogic *logic.CronLogic) *CronController {
+	return &CronController{
+		cronLogic: cronLogic,
+	}
+}
+
+var _ Router = (*CronController)(nil)
+
+func (c *CronController) RegisterRoute(r *gin.Engine) {
+	t := r.Group("/admin/cron", middleware.Auth())
+	{
+		t.GET("", c.Jobs)
+		t.GET("/:name/runs", c.Runs)
+	}
+}
+
+// Jobs
+// @Summary 定时任务列表
+// @Description 所有注册的定时任务、下次触发时间和最近的执行记录
+// @Tags 定时任务
+// @Produce json
+// @Param limit query int false "每个任务返回的执行记录数，默认 10"
+// @Success 200 {object} response.Response{data=[]logic.CronJobInfo} "成功"
+// @Failure 400 {object} response.Response "失败"
+// @Router /admin/cron [get]
+func (c *CronController) Jobs(ctx *gin.Context) {
+	limit := clampLimit(util.ToInt(ctx.Query("limit")))
+	jobs, err := c.cronLogic.Jobs(ctx, limit)
+	if err != nil {
+		response.Error(ctx, 400, err.Error())
+		return
+	}
+	response.Success(ctx, jobs)
+}
+
+// Runs
+// @Summary 定时任务执行记录
+// @Description 按时间倒序分页查询任务的执行记录
+// @Tags 定时任务
+// @Produce json
+// @Param name path string true "任务名称"
+// @Param page query int false "页码，默认 1"
+// @Param limit query int false "每页数量，默认 10"
+// @Success 200 {object} response.Response "成功"
+// @Failure 400 {object} response.Response "失败"
+// @Router /admin/cron/{name}/runs [get]
+func (c *CronController) Runs(ctx *gin.Context) {
+	name := ctx.Param("name")
+	if _, ok := cron.Lookup(name); !ok {
+		response.Error(ctx, 400, "unknown cron job "+name)
+		return
+	}
+	var p logic.Pagination
+	if err := ctx.ShouldBindQuery(&p); err != nil {
+		response.Error(ctx, 400, err.Error())
+		return
+	}
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	p.Limit = clampLimit(p.Limit)
+	runs, total, err := c.cronLogic.Runs(name, p.Page, p.Limit)
+	if err != nil {
+		response.Error(ctx, 400, err.Error())
+		return
+	}
+	response.Success(ctx, gin.H{"list": runs, "total": total})
+}
+
+// clampLimit 数量参数无效时使用默认值，最大为 maxRunsLimit
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return defaultRecentRuns
+	}
+	return min(limit, maxRunsLimit)
+}
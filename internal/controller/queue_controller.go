@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hhr0815hhr/gint/internal/logic"
+	"github.com/hhr0815hhr/gint/internal/middleware"
+	"github.com/hhr0815hhr/gint/internal/pkg/response"
+)
+
+type QueueController struct {
+	queueLogic *logic.QueueLogic
+}
+
+func NewQueueController(queueLogic *logic.QueueLogic) *QueueController {
+	return &QueueController{
+		queueLogic: queueLogic,
+	}
+}
+
+var _ Router = (*QueueController)(nil)
+
+func (c *QueueController) RegisterRoute(r *gin.Engine) {
+	t := r.Group("/admin/queue", middleware.Auth())
+	{
+		t.GET("/status", c.Status)
+	}
+}
+
+// Status
+// @Summary 消费进程状态
+// @Description 各消费进程上报的队列处理中消息数、worker 数量和按消息类型统计的处理次数与耗时
+// @Tags 队列
+// @Produce json
+// @Success 200 {object} response.Response{data=[]monitor.Status} "成功"
+// @Failure 400 {object} response.Response "失败"
+// @Router /admin/queue/status [get]
+func (c *QueueController) Status(ctx *gin.Context) {
+	list, err := c.queueLogic.Status(ctx)
+	if err != nil {
+		response.Error(ctx, 400, err.Error())
+		return
+	}
+	response.Success(ctx, list)
+}
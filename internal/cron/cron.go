@@ -1,36 +1,344 @@
 package cron
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/config"
 	"github.com/hhr0815hhr/gint/internal/log"
 	c3 "github.com/robfig/cron/v3"
 )
 
+const (
+	recordTimeout         = 5 * time.Second  // 保存一次执行记录的超时时间
+	reloadTimeout         = 10 * time.Second // 加载一次调度设置的超时时间
+	defaultReloadInterval = 30 * time.Second
+)
+
 type CronJob struct {
-	c *c3.Cron
+	c              *c3.Cron
+	locker         *Locker
+	recorder       Recorder
+	status         redis.Cmdable // 写入调度状态的 Redis，为空时不写入
+	logger         c3.Logger
+	nodeId         string
+	reloadInterval time.Duration
+
+	// 加入调度的任务，按名称索引
+	scheduled map[string]*scheduledJob
+	mu        sync.RWMutex
+	// settings 最近一次生效的调度设置，只在 Start 和 watch 中访问
+	settings map[string]Setting
+	loaded   bool
+
+	// 传给任务函数的 ctx，Stop 等待超时后取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	// done Stop 时关闭，停止重新加载调度设置
+	done chan struct{}
+}
+
+// scheduledJob 加入调度的任务
+type scheduledJob struct {
+	def Job        // 加入时的任务
+	job Job        // 按调度设置覆盖后实际调度的任务
+	id  c3.EntryID // robfig/cron 中的条目，0 表示已停止调度
+	run c3.Job     // 包装后的任务，重新调度时复用，保留重叠策略的状态
+}
+
+// Option CronJob 的可选配置
+type Option func(c *CronJob)
+
+// WithLocker 多节点部署时使用的任务锁，Job.Lock 为 true 的任务每次触发只在一个节点执行
+func WithLocker(locker *Locker) Option {
+	return func(c *CronJob) {
+		c.locker = locker
+	}
+}
+
+// WithRecorder 保存每次执行的记录
+func WithRecorder(recorder Recorder) Option {
+	return func(c *CronJob) {
+		c.recorder = recorder
+	}
+}
+
+// WithReloadInterval 重新加载调度设置的间隔，小于 0 时只在启动时加载一次
+func WithReloadInterval(interval time.Duration) Option {
+	return func(c *CronJob) {
+		c.reloadInterval = interval
+	}
 }
 
-func New() *CronJob {
-	return &CronJob{
-		c: c3.New(),
+// ConfigOptions 根据 config.Conf.Cron 生成配置
+func ConfigOptions() []Option {
+	conf := config.Conf.Cron
+	return []Option{
+		WithReloadInterval(time.Duration(conf.ReloadInterval) * time.Second),
 	}
 }
 
-func (c *CronJob) AddFunc(spec string, fn func()) {
-	fd, err := c.c.AddFunc(spec, fn)
+func New(opts ...Option) *CronJob {
+	logger := c3.PrintfLogger(log.Logger)
+	c := &CronJob{
+		c:         c3.New(c3.WithLogger(logger)),
+		logger:    logger,
+		scheduled: make(map[string]*scheduledJob),
+		done:      make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.locker != nil {
+		c.nodeId = c.locker.NodeId()
+	} else {
+		c.nodeId = defaultNodeId()
+	}
+	if c.reloadInterval == 0 {
+		c.reloadInterval = defaultReloadInterval
+	}
+	return c
+}
+
+// Add 按任务的配置包装后加入调度：重叠策略、任务锁、超时、panic 恢复
+// Start 时加载的调度设置可能覆盖任务的 Spec 或停止调度
+func (c *CronJob) Add(job Job) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.scheduled[job.Name]; ok {
+		return fmt.Errorf("cron job %s already added", job.Name)
+	}
+	s := &scheduledJob{def: job, job: job}
+	s.run = c.wrap(s)
+	id, err := c.c.AddJob(job.Spec, s.run)
 	if err != nil {
-		log.Logger.Errorf("CronJob add func %s error: %s", spec, err.Error())
+		return fmt.Errorf("add cron job %s: %w", job.Name, err)
+	}
+	s.id = id
+	c.scheduled[job.Name] = s
+	log.Logger.Infof("CronJob add %s %s, id: %d", job.Name, job.Spec, id)
+	return nil
+}
+
+// AddJobs 加入所有注册的任务
+func (c *CronJob) AddJobs() {
+	for _, job := range Jobs() {
+		if err := c.Add(job); err != nil {
+			log.Logger.Error(err.Error())
+		}
+	}
+}
+
+// wrap 由外到内依次为重叠策略、任务锁，最内层带超时执行任务函数并恢复 panic
+// c3.SkipIfStillRunning 在 panic 时不会释放，panic 需要在它的内层恢复
+// 任务锁按执行时的 Spec 计算保留时间，调度设置修改 Spec 后不需要重新包装
+func (c *CronJob) wrap(s *scheduledJob) c3.Job {
+	var wrappers []c3.JobWrapper
+	switch s.def.Overlap {
+	case OverlapSkip, "":
+		wrappers = append(wrappers, c3.SkipIfStillRunning(c.logger))
+	case OverlapDelay:
+		wrappers = append(wrappers, c3.DelayIfStillRunning(c.logger))
+	}
+	if s.def.Lock && c.locker == nil {
+		log.Logger.Warnf("CronJob %s requires a lock but no locker is configured, it will run on every node", s.def.Name)
+	}
+	return c3.NewChain(wrappers...).Then(c3.FuncJob(func() {
+		c.mu.RLock()
+		job := s.job
+		c.mu.RUnlock()
+		if job.Lock && c.locker != nil {
+			c.locker.Wrap(job.Name, job.Spec, job.LockTTL, func() {
+				c.run(c.ctx, job)
+			})()
+			return
+		}
+		c.run(c.ctx, job)
+	}))
+}
+
+// reload 加载调度设置，与上一次相同时不做处理，加载失败时保持当前的调度
+func (c *CronJob) reload() {
+	ctx, cancel := context.WithTimeout(c.ctx, reloadTimeout)
+	defer cancel()
+	settings, err := loadSettings(ctx)
+	if err != nil {
+		log.Logger.Errorf("Load cron settings failed, keep current schedules: %v", err)
+		return
+	}
+	if c.loaded && maps.Equal(settings, c.settings) {
 		return
 	}
-	log.Logger.Infof("CronJob add func %s, id: %d", spec, fd)
+	c.settings, c.loaded = settings, true
+	c.apply(settings)
 }
 
-func (c *CronJob) AddFuncs() {
-	for _, item := range cronItems {
-		c.AddFunc(item.Time, item.Func)
+// apply 按调度设置重新调度任务：Spec 变化时先加入新的条目再删除旧的，停止调度时删除条目
+// 正在执行的任务不受影响，重叠策略的状态在重新调度后保留
+func (c *CronJob) apply(settings map[string]Setting) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name := range settings {
+		if _, ok := c.scheduled[name]; !ok {
+			log.Logger.Warnf("Cron setting of %s does not match any job, ignored", name)
+		}
+	}
+	for name, s := range c.scheduled {
+		job, disabled := settings[name].apply(s.def)
+		if disabled {
+			if s.id != 0 {
+				c.c.Remove(s.id)
+				s.id = 0
+				log.Logger.Infof("CronJob %s disabled", name)
+			}
+			continue
+		}
+		if s.id != 0 && job.Spec == s.job.Spec {
+			continue
+		}
+		id, err := c.c.AddJob(job.Spec, s.run)
+		if err != nil {
+			log.Logger.Errorf("Schedule CronJob %s with %q failed, setting ignored: %v", name, job.Spec, err)
+			continue
+		}
+		if s.id != 0 {
+			c.c.Remove(s.id)
+		}
+		s.id, s.job = id, job
+		log.Logger.Infof("CronJob %s scheduled with %s, id: %d", name, job.Spec, id)
+	}
+}
+
+// watch 定时重新加载调度设置，直到 Stop
+func (c *CronJob) watch() {
+	ticker := time.NewTicker(c.reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.reload()
+		}
+	}
+}
+
+// RunNow 在前台立即执行一次 name 任务，不经过重叠策略和任务锁，ctx 取消时任务的 ctx 随之取消
+// 执行失败、超时或 panic 时返回 error，执行记录同样会保存
+func (c *CronJob) RunNow(ctx context.Context, name string) (Run, error) {
+	job, ok := Lookup(name)
+	if !ok {
+		return Run{}, fmt.Errorf("cron job %s is not registered", name)
+	}
+	record := c.run(ctx, job)
+	if record.Status != RunSuccess {
+		return record, fmt.Errorf("cron job %s %s: %s", name, record.Status, record.Error)
+	}
+	return record, nil
+}
+
+// run 执行一次任务，记录耗时、结果和错误，panic 视为执行失败
+func (c *CronJob) run(ctx context.Context, job Job) (record Run) {
+	record = Run{Job: job.Name, Host: c.nodeId, StartedAt: time.Now()}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Logger.Errorf("CronJob %s panic: %v\n%s", job.Name, r, debug.Stack())
+			record.Status, record.Error = RunPanic, fmt.Sprint(r)
+		}
+		record.FinishedAt = time.Now()
+		c.record(record)
+	}()
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+	err := job.Func(ctx)
+	record.Status = RunSuccess
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		record.Status = RunTimeout
+		if err == nil {
+			err = fmt.Errorf("timed out after %s", job.Timeout)
+		}
+	} else if err != nil {
+		record.Status = RunFailed
+	}
+	if err != nil {
+		record.Error = err.Error()
+		log.Logger.Errorf("CronJob %s failed after %s: %v", job.Name, time.Since(record.StartedAt), err)
+		return record
 	}
+	log.Logger.Infof("CronJob %s finished in %s", job.Name, time.Since(record.StartedAt))
+	return record
 }
 
+// record 保存执行记录，停止调度时任务的 ctx 可能已经取消，不使用它
+func (c *CronJob) record(run Run) {
+	if c.recorder == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	if err := c.recorder.Record(ctx, run); err != nil {
+		log.Logger.Errorf("Record run of CronJob %s failed: %v", run.Job, err)
+	}
+}
+
+// schedules 调度中的任务和 robfig/cron 条目的触发时间，按名称排序
+func (c *CronJob) schedules() []Schedule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list := make([]Schedule, 0, len(c.scheduled))
+	for _, s := range c.scheduled {
+		schedule := Schedule{Job: s.job, Disabled: s.id == 0}
+		if entry := c.c.Entry(s.id); entry.Valid() {
+			schedule.Next, schedule.Prev = entry.Next, entry.Prev
+		}
+		list = append(list, schedule)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Job.Name < list[j].Job.Name })
+	return list
+}
+
+// LockHolder 当前正在执行 name 任务 (持有锁) 的节点，没有节点持有时返回空字符串
+func (c *CronJob) LockHolder(ctx context.Context, name string) (string, time.Duration, error) {
+	if c.locker == nil {
+		return "", 0, nil
+	}
+	return c.locker.Holder(ctx, name)
+}
+
+// Start 加载调度设置后开始调度，之后按 reloadInterval 重新加载
 func (c *CronJob) Start() {
+	c.reload()
 	c.c.Start()
+	active.Store(c)
+	if c.reloadInterval > 0 {
+		go c.watch()
+	}
+	if c.status != nil {
+		go c.report()
+	}
 	log.Logger.Info("CronJob started")
 }
+
+// Stop 停止调度并等待正在执行的任务结束，ctx 结束时取消任务的 ctx 后返回
+func (c *CronJob) Stop(ctx context.Context) {
+	active.CompareAndSwap(c, nil)
+	close(c.done)
+	done := c.c.Stop()
+	select {
+	case <-done.Done():
+	case <-ctx.Done():
+		log.Logger.Warn("CronJob stop timed out, cancel running jobs")
+	}
+	c.cancel()
+}
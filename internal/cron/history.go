@@ -0,0 +1,119 @@
+package cron
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/log"
+	c3 "github.com/robfig/cron/v3"
+)
+
+// RunStatus 一次执行的结果
+type RunStatus string
+
+const (
+	RunSuccess RunStatus = "success"
+	RunFailed  RunStatus = "failed"
+	RunTimeout RunStatus = "timeout"
+	RunPanic   RunStatus = "panic"
+)
+
+// Run 一次任务执行的记录，被重叠策略跳过或被其他节点加锁的触发不会产生记录
+type Run struct {
+	Job        string
+	Host       string // 执行任务的节点，与 Locker 的 NodeId 相同
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     RunStatus
+	Error      string
+}
+
+// Duration 执行耗时
+func (r Run) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// Recorder 保存任务的执行记录，保存失败只记录日志，不影响任务
+type Recorder interface {
+	Record(ctx context.Context, run Run) error
+}
+
+// Schedule 任务的调度信息
+type Schedule struct {
+	Job      Job       // 按调度设置覆盖 Spec 后的任务
+	Disabled bool      // 调度设置停止了该任务
+	Next     time.Time // 下次触发时间，停止调度时为零值
+	Prev     time.Time // 上次触发时间，没有调度进程或还没有触发过时为零值
+	Nodes    []string  // 调度该任务的 gint cron 进程，为空时 Next 是按调度设置计算的
+}
+
+// active 当前进程中运行的调度器
+var active atomic.Pointer[CronJob]
+
+// Schedules 所有任务的调度信息，按名称排序
+// 当前进程运行着调度器时取自 robfig/cron 的 Entries，否则 (例如在 gint serve 中查询) 读取 gint cron 进程写入 client 的调度状态，
+// 没有调度进程在运行的任务按调度设置和 Spec 计算下次触发时间
+func Schedules(ctx context.Context, client redis.Cmdable) []Schedule {
+	if c := active.Load(); c != nil {
+		list := c.schedules()
+		for i := range list {
+			list[i].Nodes = []string{c.nodeId}
+		}
+		return list
+	}
+	settings, err := loadSettings(ctx)
+	if err != nil {
+		log.Logger.Errorf("Load cron settings failed, use registered specs: %v", err)
+	}
+	reported := make(map[string][]reportedEntry)
+	if client != nil {
+		statuses, err := ListStatus(ctx, client)
+		if err != nil {
+			log.Logger.Errorf("List cron status failed, compute next run times: %v", err)
+		}
+		for _, status := range statuses {
+			for _, e := range status.Entries {
+				reported[e.Name] = append(reported[e.Name], reportedEntry{EntryStatus: e, node: status.Node, updatedAt: status.UpdatedAt})
+			}
+		}
+	}
+	now := time.Now()
+	list := make([]Schedule, 0)
+	for _, job := range Jobs() {
+		job, disabled := settings[job.Name].apply(job)
+		s := Schedule{Job: job, Disabled: disabled}
+		if entries := reported[job.Name]; len(entries) > 0 {
+			s.merge(entries)
+		}
+		// 调度状态最多延迟 staleAfter，已经过去的触发时间按 Spec 重新计算
+		if schedule, err := c3.ParseStandard(s.Job.Spec); err == nil && !s.Disabled && s.Next.Before(now) {
+			s.Next = schedule.Next(now)
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// reportedEntry 某个调度进程写入的任务状态
+type reportedEntry struct {
+	EntryStatus
+	node      string
+	updatedAt time.Time
+}
+
+// merge 按调度进程写入的状态覆盖，Spec 和是否停止取最近写入的，Prev 取所有进程中最晚的一次
+func (s *Schedule) merge(entries []reportedEntry) {
+	latest := entries[0]
+	for _, e := range entries {
+		s.Nodes = append(s.Nodes, e.node)
+		if e.updatedAt.After(latest.updatedAt) {
+			latest = e
+		}
+		if e.Prev.After(s.Prev) {
+			s.Prev = e.Prev
+		}
+	}
+	s.Job.Spec, s.Disabled, s.Next = latest.Spec, latest.Disabled, latest.Next
+}
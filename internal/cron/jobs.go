@@ -1,9 +1,8 @@
 package cron
 
-var (
-	// 存储所有的定时任务
-	cronItems = []CronItem{
-		{Time: CronDayly, Func: func() {}},   // 每天0点执行
-		{Time: Every("1s"), Func: func() {}}, // 每1秒执行
-	}
-)
+import "context"
+
+// 新增定时任务时在各模块的 init 中调用 Register，不需要修改这里
+func init() {
+	Register(Job{Name: "daily", Spec: CronDayly, Func: func(ctx context.Context) error { return nil }, Lock: true}) // 每天0点执行，多节点时只执行一次
+}
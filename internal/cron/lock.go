@@ -0,0 +1,218 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/log"
+	c3 "github.com/robfig/cron/v3"
+)
+
+const (
+	lockPrefix     = "cron:lock:"
+	defaultLockTTL = time.Minute
+	// heldPrefix 执行完成后保留锁期间锁的值，加在 nodeId 前面，与执行中的锁区分
+	heldPrefix = "held:"
+)
+
+// renewScript 锁仍由自己持有时延长租约; KEYS: lock; ARGV: owner, ttl(毫秒)
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript 锁仍由自己持有时释放，hold 大于 0 时改为保留状态的值，hold 之后再过期; KEYS: lock; ARGV: owner, hold(毫秒), 保留状态的值
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	return redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[2])
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// Locker 基于 Redis 租约的任务锁，多个节点运行 gint cron 时每次触发只有一个节点执行
+// 执行期间按租约的 1/3 自动续约，节点崩溃后锁在租约到期后释放
+type Locker struct {
+	client redis.Cmdable
+	nodeId string
+	ttl    time.Duration
+}
+
+// LockOption Locker 的可选配置
+type LockOption func(l *Locker)
+
+// WithNodeId 当前节点的标识，记录在锁中用来查看哪个节点在执行任务
+func WithNodeId(nodeId string) LockOption {
+	return func(l *Locker) {
+		l.nodeId = nodeId
+	}
+}
+
+// WithLockTTL 默认的租约时间，Job.LockTTL 可以单独设置
+func WithLockTTL(ttl time.Duration) LockOption {
+	return func(l *Locker) {
+		l.ttl = ttl
+	}
+}
+
+// LockConfigOptions 根据 config.Conf.Cron 生成锁配置
+func LockConfigOptions() []LockOption {
+	conf := config.Conf.Cron
+	return []LockOption{
+		WithNodeId(conf.NodeId),
+		WithLockTTL(time.Duration(conf.LockTTL) * time.Second),
+	}
+}
+
+// NewLocker 创建任务锁
+func NewLocker(client redis.Cmdable, opts ...LockOption) *Locker {
+	l := &Locker{client: client}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.nodeId == "" {
+		l.nodeId = defaultNodeId()
+	}
+	if l.ttl <= 0 {
+		l.ttl = defaultLockTTL
+	}
+	return l
+}
+
+// defaultNodeId 没有配置节点标识时使用主机名和进程号
+func defaultNodeId() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// NodeId 当前节点的标识
+func (l *Locker) NodeId() string {
+	return l.nodeId
+}
+
+func lockKey(name string) string {
+	return lockPrefix + name
+}
+
+// Lease 持有中的锁，Release 之前在后台自动续约
+type Lease struct {
+	l    *Locker
+	key  string
+	ttl  time.Duration
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Acquire 尝试获取 name 的锁，已被其他节点持有时返回 nil, nil
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		ttl = l.ttl
+	}
+	key := lockKey(name)
+	ok, err := l.client.SetNX(ctx, key, l.nodeId, ttl).Result()
+	if err != nil || !ok {
+		return nil, err
+	}
+	lease := &Lease{l: l, key: key, ttl: ttl, stop: make(chan struct{}), done: make(chan struct{})}
+	go lease.renew()
+	return lease, nil
+}
+
+// renew 定时续约，锁已经不属于自己时停止
+func (lease *Lease) renew() {
+	defer close(lease.done)
+	ticker := time.NewTicker(lease.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lease.stop:
+			return
+		case <-ticker.C:
+			n, err := renewScript.Run(context.Background(), lease.l.client, []string{lease.key}, lease.l.nodeId, lease.ttl.Milliseconds()).Int()
+			if err != nil {
+				log.Logger.Errorf("Renew cron lock %s failed: %v", lease.key, err)
+				continue
+			}
+			if n == 0 {
+				log.Logger.Warnf("Cron lock %s is lost, the job may run on another node at the same time", lease.key)
+				return
+			}
+		}
+	}
+}
+
+// Release 停止续约并释放锁，hold 大于 0 时锁保留到 hold 之后，避免时钟稍慢的节点在同一次触发中重复执行
+// 保留期间锁的值加上 heldPrefix，Holder 不会把它当作正在执行
+func (lease *Lease) Release(hold time.Duration) {
+	lease.once.Do(func() {
+		close(lease.stop)
+		<-lease.done
+		err := releaseScript.Run(context.Background(), lease.l.client, []string{lease.key}, lease.l.nodeId, hold.Milliseconds(), heldPrefix+lease.l.nodeId).Err()
+		if err != nil && err != redis.Nil {
+			log.Logger.Errorf("Release cron lock %s failed: %v", lease.key, err)
+		}
+	})
+}
+
+// Holder 正在执行 name 任务 (持有锁) 的节点和剩余租约，没有节点在执行时返回空字符串
+// 执行完成后保留锁的阶段 (见 Lease.Release) 不算作执行中
+func (l *Locker) Holder(ctx context.Context, name string) (string, time.Duration, error) {
+	key := lockKey(name)
+	holder, err := l.client.Get(ctx, key).Result()
+	if err == redis.Nil || strings.HasPrefix(holder, heldPrefix) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	ttl, err := l.client.PTTL(ctx, key).Result()
+	return holder, ttl, err
+}
+
+// JobWrapper 以 robfig/cron 的 JobWrapper 形式使用 Wrap
+func (l *Locker) JobWrapper(name, spec string, ttl time.Duration) c3.JobWrapper {
+	return func(j c3.Job) c3.Job {
+		return c3.FuncJob(l.Wrap(name, spec, ttl, j.Run))
+	}
+}
+
+// Wrap 包装任务，每次触发先获取锁，获取不到说明其他节点正在执行或已经执行过这次触发
+// 执行完成后锁保留到下次触发前 (间隔的 90%)，spec 无法解析时立即释放
+func (l *Locker) Wrap(name, spec string, ttl time.Duration, fn func()) func() {
+	schedule, err := c3.ParseStandard(spec)
+	if err != nil {
+		log.Logger.Warnf("Parse cron spec %s of %s failed, release lock right after run: %v", spec, name, err)
+	}
+	return func() {
+		start := time.Now()
+		lease, err := l.Acquire(context.Background(), name, ttl)
+		if err != nil {
+			log.Logger.Errorf("Acquire cron lock of %s failed, skip this run: %v", name, err)
+			return
+		}
+		if lease == nil {
+			log.Logger.Debugf("Cron job %s is locked by another node, skip", name)
+			return
+		}
+		var hold time.Duration
+		defer func() {
+			lease.Release(hold)
+		}()
+		fn()
+		if schedule != nil {
+			next := schedule.Next(start)
+			hold = time.Until(start.Add(next.Sub(start) * 9 / 10))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	c3 "github.com/robfig/cron/v3"
+)
+
+// JobFunc 定时任务函数，ctx 在超时或停止调度时取消
+type JobFunc func(ctx context.Context) error
+
+// Overlap 上一次执行还没有结束时再次触发的处理策略
+type Overlap string
+
+const (
+	OverlapSkip  Overlap = "skip"  // 跳过本次触发 (默认)
+	OverlapDelay Overlap = "delay" // 等上一次结束后再执行
+	OverlapAllow Overlap = "allow" // 同时执行
+)
+
+// Job 注册的定时任务
+type Job struct {
+	Name    string        // 唯一的任务名称，加锁时作为锁的 key
+	Spec    string        // cron 表达式，支持 @every 等描述符
+	Func    JobFunc       // 任务函数，返回的错误记录到日志
+	Timeout time.Duration // 单次执行的超时时间，超时后取消 ctx，0 表示不限制
+	Overlap Overlap       // 上一次还没有结束时的处理策略，默认 OverlapSkip
+	Lock    bool          // 多节点部署时每次触发只在一个节点执行
+	LockTTL time.Duration // 锁的租约时间，默认使用 Locker 的配置，执行期间自动续约
+}
+
+var (
+	jobs   = make(map[string]Job)
+	jobsMu sync.RWMutex
+)
+
+// Register 注册定时任务，一般在各模块的 init 中调用，名称重复或 Spec 无法解析时 panic
+func Register(job Job) {
+	if job.Name == "" || job.Func == nil {
+		panic("cron: job name and func are required")
+	}
+	if _, err := c3.ParseStandard(job.Spec); err != nil {
+		panic(fmt.Sprintf("cron: invalid spec %q of job %s: %v", job.Spec, job.Name, err))
+	}
+	switch job.Overlap {
+	case OverlapSkip, OverlapDelay, OverlapAllow:
+	case "":
+		job.Overlap = OverlapSkip
+	default:
+		panic(fmt.Sprintf("cron: unknown overlap policy %q of job %s", job.Overlap, job.Name))
+	}
+
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	if _, ok := jobs[job.Name]; ok {
+		panic(fmt.Sprintf("cron: job %s already registered", job.Name))
+	}
+	jobs[job.Name] = job
+}
+
+// Lookup 按名称查找注册的任务
+func Lookup(name string) (Job, bool) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	job, ok := jobs[name]
+	return job, ok
+}
+
+// Jobs 所有注册的任务，按名称排序
+func Jobs() []Job {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	list := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,67 @@
+package cron
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hhr0815hhr/gint/internal/config"
+)
+
+// Setting 按任务名称覆盖注册时的调度设置
+type Setting struct {
+	Spec     string // 为空时使用注册时的 Spec
+	Disabled bool   // 停止调度该任务，gint cron run 仍然可以手动执行
+}
+
+// SettingSource 调度设置的来源，调度器定时重新加载，变化后立即生效
+type SettingSource interface {
+	Settings(ctx context.Context) (map[string]Setting, error)
+}
+
+var (
+	source   SettingSource
+	sourceMu sync.RWMutex
+)
+
+// SetSource 设置调度设置的来源，没有设置时使用注册时的 Spec
+func SetSource(s SettingSource) {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+	source = s
+}
+
+// loadSettings 从当前的来源加载调度设置，没有设置来源时返回 nil
+func loadSettings(ctx context.Context) (map[string]Setting, error) {
+	sourceMu.RLock()
+	s := source
+	sourceMu.RUnlock()
+	if s == nil {
+		return nil, nil
+	}
+	return s.Settings(ctx)
+}
+
+var _ SettingSource = ConfigSource{}
+
+// ConfigSource 每次重新读取 cron 配置文件中的 jobs
+type ConfigSource struct{}
+
+func (ConfigSource) Settings(ctx context.Context) (map[string]Setting, error) {
+	conf, err := config.ReadCron()
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]Setting, len(conf.Jobs))
+	for _, job := range conf.Jobs {
+		settings[job.Name] = Setting{Spec: job.Spec, Disabled: job.Disabled}
+	}
+	return settings, nil
+}
+
+// apply 按设置得到任务实际的调度，第二个返回值表示是否停止调度
+func (s Setting) apply(job Job) (Job, bool) {
+	if s.Spec != "" {
+		job.Spec = s.Spec
+	}
+	return job, s.Disabled
+}
@@ -0,0 +1,95 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/log"
+)
+
+const (
+	statusKey      = "cron:status" // hash，field 为调度进程的 nodeId
+	ReportInterval = 10 * time.Second
+	// staleAfter 超过这个时间没有更新的调度进程视为已经退出
+	staleAfter = 3 * ReportInterval
+)
+
+// Status 一个 gint cron 进程的调度状态，gint serve 等没有运行调度器的进程读取它展示任务的触发时间
+type Status struct {
+	Node      string        `json:"node"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Entries   []EntryStatus `json:"entries"`
+}
+
+// EntryStatus 调度进程中一个任务实际生效的 Spec 和触发时间
+type EntryStatus struct {
+	Name     string    `json:"name"`
+	Spec     string    `json:"spec"`
+	Disabled bool      `json:"disabled"`
+	Next     time.Time `json:"next"`
+	Prev     time.Time `json:"prev"`
+}
+
+// WithStatus 每隔 ReportInterval 把调度状态写入 Redis，Stop 时删除
+func WithStatus(client redis.Cmdable) Option {
+	return func(c *CronJob) {
+		c.status = client
+	}
+}
+
+// collect 当前调度器的状态
+func (c *CronJob) collect() Status {
+	schedules := c.schedules()
+	entries := make([]EntryStatus, len(schedules))
+	for i, s := range schedules {
+		entries[i] = EntryStatus{Name: s.Job.Name, Spec: s.Job.Spec, Disabled: s.Disabled, Next: s.Next, Prev: s.Prev}
+	}
+	return Status{Node: c.nodeId, UpdatedAt: time.Now(), Entries: entries}
+}
+
+// report 定时写入调度状态，直到 Stop
+func (c *CronJob) report() {
+	ticker := time.NewTicker(ReportInterval)
+	defer ticker.Stop()
+	for {
+		if err := writeStatus(c.ctx, c.status, c.collect()); err != nil {
+			log.Logger.Errorf("Report cron status of %s failed: %v", c.nodeId, err)
+		}
+		select {
+		case <-c.done:
+			c.status.HDel(context.Background(), statusKey, c.nodeId)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeStatus(ctx context.Context, client redis.Cmdable, status Status) error {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return client.HSet(ctx, statusKey, status.Node, b).Err()
+}
+
+// ListStatus 所有仍在运行的调度进程的状态，按 nodeId 排序，顺便清理已经退出的进程
+func ListStatus(ctx context.Context, client redis.Cmdable) ([]Status, error) {
+	all, err := client.HGetAll(ctx, statusKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Status, 0, len(all))
+	for node, raw := range all {
+		var status Status
+		if json.Unmarshal([]byte(raw), &status) != nil || time.Since(status.UpdatedAt) > staleAfter {
+			client.HDel(ctx, statusKey, node)
+			continue
+		}
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Node < list[j].Node })
+	return list, nil
+}
@@ -12,10 +12,5 @@ func Every(str string) string {
 	return fmt.Sprintf("@every %s", str)
 }
 
-type CronFunc func()
-type CronItem struct {
-	Time string
-	Func CronFunc
-}
 
 
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/database"
+	"gorm.io/gorm"
+)
+
+// CronRun 定时任务的一次执行记录
+type CronRun struct {
+	Id         uint64    `gorm:"primarykey" json:"id"`
+	JobName    string    `gorm:"size:100;not null;index:idx_job_started,priority:1" json:"job_name"`
+	Host       string    `gorm:"size:100;not null" json:"host"`
+	Status     string    `gorm:"size:20;not null" json:"status"` // success, failed, timeout, panic
+	Error      string    `gorm:"size:1000" json:"error"`
+	StartedAt  time.Time `gorm:"not null;index:idx_job_started,priority:2" json:"started_at"`
+	FinishedAt time.Time `gorm:"not null" json:"finished_at"`
+	DurationMs int64     `gorm:"not null;default:0" json:"duration_ms"`
+}
+
+type CronRunRepo struct {
+	*database.BaseRepository[CronRun]
+}
+
+func NewCronRunRepo(db *gorm.DB) *CronRunRepo {
+	return &CronRunRepo{
+		BaseRepository: database.NewBaseRepository[CronRun](db),
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/database"
+	"gorm.io/gorm"
+)
+
+// CronSetting 按任务名称覆盖定时任务的调度设置，cron.source 为 db 时生效，修改后 gint cron 定时重新加载
+type CronSetting struct {
+	Id        uint64 `gorm:"primarykey"`
+	JobName   string `gorm:"size:100;not null;uniqueIndex"`
+	Spec      string `gorm:"size:100;not null;default:''"` // cron 表达式，为空时使用注册时的 Spec
+	Disabled  bool   `gorm:"not null;default:false"`
+	UpdatedAt time.Time
+}
+
+type CronSettingRepo struct {
+	*database.BaseRepository[CronSetting]
+}
+
+func NewCronSettingRepo(db *gorm.DB) *CronSettingRepo {
+	return &CronSettingRepo{
+		BaseRepository: database.NewBaseRepository[CronSetting](db),
+	}
+}
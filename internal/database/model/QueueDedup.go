@@ -0,0 +1,12 @@
+package model
+
+import (
+	"time"
+)
+
+// QueueDedup mysql 队列驱动的消费端去重记录，过期的记录由去重存储分批清理
+type QueueDedup struct {
+	DedupKey  string    `gorm:"primarykey;size:191"` // 超过字段长度的 key 使用摘要
+	State     string    `gorm:"size:16;not null"`    // processing 处理中, done 已经处理成功
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
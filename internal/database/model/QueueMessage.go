@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+)
+
+// QueueMessage mysql 队列驱动中等待处理或处理中的消息
+// 领取消息时按通道逐个查询 queue_name = ? AND lane = ? AND available_at <= ? ORDER BY available_at, id LIMIT 1，
+// idx_queue_due 上是一次范围扫描，未到期的延迟消息和处理中的消息 (available_at 在将来) 不在扫描范围内；
+// InnoDB 二级索引末尾带有主键，ORDER BY available_at, id 不需要 filesort
+type QueueMessage struct {
+	Id          uint64    `gorm:"primarykey"`
+	QueueName   string    `gorm:"size:100;not null;index:idx_queue_due,priority:1"`
+	MsgId       string    `gorm:"size:64;not null;index"`
+	Lane        int       `gorm:"not null;default:1;index:idx_queue_due,priority:2"` // 优先级通道，0 为最高优先级
+	Payload     string    `gorm:"type:text;not null"`
+	AvailableAt time.Time `gorm:"not null;index:idx_queue_due,priority:3"` // 可以被领取的时间，领取后延长为可见性超时的截止时间
+	Deliveries  int       `gorm:"not null;default:0"`                      // 被领取的次数
+	LockToken   string    `gorm:"size:32;index"`                           // 最后一次领取的凭证，确认时校验
+	LockedBy    string    `gorm:"size:100"`
+	CreatedAt   time.Time
+}
+
+// QueueMessageArchive 处理完成的消息
+type QueueMessageArchive struct {
+	Id          uint64 `gorm:"primarykey"`
+	QueueName   string `gorm:"size:100;not null;index"`
+	MsgId       string `gorm:"size:64;not null;index"`
+	Payload     string `gorm:"type:text;not null"`
+	Deliveries  int    `gorm:"not null;default:0"`
+	CreatedAt   time.Time
+	CompletedAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// 发件箱消息状态
+const (
+	OutboxPending = 0 // 等待投递
+	OutboxSent    = 1 // 已投递
+	OutboxFailed  = 2 // 超过最大重试次数，不再投递
+)
+
+// QueueOutbox 事务发件箱，与业务数据在同一事务中写入，由 relay 投递到队列
+type QueueOutbox struct {
+	Id          uint64     `gorm:"primarykey"`
+	QueueName   string     `gorm:"size:100;not null"`
+	MsgId       string     `gorm:"size:64;not null"`
+	Payload     string     `gorm:"type:text;not null"`
+	Status      int        `gorm:"not null;default:0;index:idx_status_available,priority:1"`
+	Attempts    int        `gorm:"not null;default:0"`
+	LastError   string     `gorm:"size:500"`
+	AvailableAt time.Time  `gorm:"not null;index:idx_status_available,priority:2"` // 可以被投递的时间，relay 领取后延长为租约的截止时间
+	LockToken   string     `gorm:"size:32"`                                        // 最后一次领取的凭证，更新投递结果时校验
+	SentAt      *time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
@@ -9,5 +9,12 @@ func init() {
 }
 
 func autoMigrate() {
-	mysql.DB.AutoMigrate()
+	mysql.DB.AutoMigrate(&QueueOutbox{}, &QueueMessage{}, &QueueMessageArchive{}, &QueueDedup{}, &CronRun{}, &CronSetting{})
+	// 领取消息改用 idx_queue_due，删除之前版本的索引
+	m := mysql.DB.Migrator()
+	for _, name := range []string{"idx_queue_available", "idx_queue_claim"} {
+		if m.HasIndex(&QueueMessage{}, name) {
+			m.DropIndex(&QueueMessage{}, name)
+		}
+	}
 }
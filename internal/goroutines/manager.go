@@ -1,14 +1,137 @@
 package goroutines
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/hhr0815hhr/gint/internal"
+	"github.com/hhr0815hhr/gint/internal/cache"
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/database/mysql"
 	"github.com/hhr0815hhr/gint/internal/goroutines/queue_consumer"
 	"github.com/hhr0815hhr/gint/internal/log"
 	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/monitor"
+	"github.com/hhr0815hhr/gint/internal/queue/outbox"
 )
 
-func RunGlobalGoroutines() {
-	log.Logger.Println("[goroutine]消息队列消费者启动...success")
-	// 优化为遍历topic切片，每个topic启动n个消费者
-	queue_consumer.Consumer(queue.DefaultQueueName, internal.App.Data["queue"].(queue.Driver))
+const defaultDrainTimeout = 30 * time.Second
+
+// RunGlobalGoroutines 按配置的队列拓扑启动消费者，queues 不为空时只启动其中的队列
+// 任意一个消费者异常退出时会取消其余消费者，全部退出后返回
+// 开启事务发件箱时同时启动 relay，运行期间定时上报各队列处理中的消息数 (见 monitor)
+// ctx 取消后停止拉取消息，等待处理中的消息完成 (最多 queue.drainTimeout)，超时后取消 handler 并把未完成的消息交还给队列
+func RunGlobalGoroutines(ctx context.Context, queues []string) error {
+	topics, err := selectTopics(queues)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// handler 使用的 ctx 不随 ctx 取消，排空超时后才取消
+	work, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+
+	driver := internal.App.Data["queue"].(queue.Driver)
+	if cache.Client != nil {
+		// 排空期间也继续上报，便于观察处理中的消息数
+		report, stopReport := context.WithCancel(context.WithoutCancel(ctx))
+		defer stopReport()
+		go monitor.Report(report, cache.Client, monitor.Node(config.Conf.Queue.ConsumerId))
+	}
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		ferr error
+	)
+	for _, topic := range topics {
+		n := max(topic.Consumers, 1)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := queue_consumer.Consumer(ctx, work, topic, driver); err != nil {
+					log.Logger.Errorf("[goroutine]队列 %s 消费者异常退出: %v", topic.Name, err)
+					once.Do(func() {
+						ferr = err
+						cancel()
+					})
+				}
+			}()
+		}
+		log.Logger.Printf("[goroutine]队列 %s 启动 %d 个消费者...success", topic.Name, n)
+	}
+	if config.Conf.Queue.Outbox.Enable {
+		relay := outbox.NewRelay(mysql.DB, driver, outbox.ConfigOptions()...)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = relay.Run(ctx)
+		}()
+		log.Logger.Println("[goroutine]事务发件箱 relay 启动...success")
+	}
+	wg.Wait()
+	drain(driver, abort)
+	return ferr
+}
+
+// drain 等待处理中的消息完成，超时后取消 handler，并让驱动交还未完成的消息
+func drain(driver queue.Driver, abort context.CancelFunc) {
+	timeout := time.Duration(config.Conf.Queue.DrainTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	log.Logger.Printf("[goroutine]停止拉取消息，等待处理中的消息完成，最多等待 %s", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := queue.Drain(ctx); err == nil {
+		log.Logger.Println("[goroutine]处理中的消息已全部完成")
+		return
+	}
+
+	log.Logger.Warnf("[goroutine]等待超时，取消仍在处理的消息: %v", queue.InFlight())
+	abort()
+	r, ok := driver.(queue.Requeuer)
+	if !ok {
+		return
+	}
+	// 给 handler 一点时间响应取消，只有返回 queue.ErrAborted 的消息会交还给队列
+	grace, cancelGrace := context.WithTimeout(context.Background(), time.Second)
+	defer cancelGrace()
+	if err := queue.Drain(grace); err != nil {
+		log.Logger.Warnf("[goroutine]仍有 handler 没有响应取消，这些消息不会交还，由驱动的超时机制恢复或者随进程退出丢失: %v", queue.InFlight())
+	}
+	n, err := r.RequeueUnfinished(context.Background())
+	if err != nil {
+		log.Logger.Errorf("[goroutine]交还未完成的消息失败: %v", err)
+	}
+	if n > 0 {
+		log.Logger.Warnf("[goroutine]已将 %d 条未完成的消息交还给队列", n)
+	}
+}
+
+// selectTopics 从配置中选出需要消费的队列
+func selectTopics(queues []string) ([]config.Topic, error) {
+	topics := config.Conf.Queue.Topics
+	if len(topics) == 0 {
+		topics = []config.Topic{{Name: queue.DefaultQueueName, Consumers: 1}}
+	}
+	if len(queues) == 0 {
+		return topics, nil
+	}
+	byName := make(map[string]config.Topic, len(topics))
+	for _, t := range topics {
+		byName[t.Name] = t
+	}
+	selected := make([]config.Topic, 0, len(queues))
+	for _, name := range queues {
+		t, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("queue %s is not configured in queue.topics", name)
+		}
+		selected = append(selected, t)
+	}
+	return selected, nil
 }
@@ -3,35 +3,198 @@ package queue_consumer
 import (
 	"context"
 	"fmt"
+	"slices"
+	"time"
 
-	_const "github.com/hhr0815hhr/gint/internal/const"
+	"github.com/hhr0815hhr/gint/internal/config"
 	"github.com/hhr0815hhr/gint/internal/log"
 	"github.com/hhr0815hhr/gint/internal/queue"
 )
 
-var hd = &ConsumerHandler{}
+const defaultBatchWait = 100 * time.Millisecond // 未配置 batchWait 时凑批的等待时间
 
-func Consumer(queueName string, driver queue.Driver) {
-	ctx := context.Background()
-	handler := func(ctx context.Context, message *queue.Message) error {
-		fmt.Printf("Consumed type: %s, data: %v, Headers: %v\n", message.MsgType, message.Body, message.Headers)
-		if message.ReInCount > 3 {
-			// 放入死信队列
-			return queue.PushQueue(message, queue.DeadQueueName)
+// Consumer 消费指定队列直到 ctx 取消，topic.MsgTypes 不为空时只接受其中的消息类型
+// topic.BatchSize 大于 1 且驱动支持批量消费时，注册了批量处理函数的消息类型按批处理
+// ctx 取消后停止拉取消息，handler 使用 work，work 取消时正在处理的消息以 queue.ErrAborted 结束，不会重试
+func Consumer(ctx, work context.Context, topic config.Topic, driver queue.Driver) error {
+	queueName := topic.Name
+	// 按消息类型调用 queue.Handle 注册的处理函数，外层包装 queue.Use 注册的中间件
+	dispatch := queue.Chain(queue.Dispatch)
+	handler := func(_ context.Context, message *queue.Message) error {
+		// 处理函数通过 queue.EnvelopeFrom(ctx) 获取消息的追踪信息，处理过程中发布的消息会继承这些信息
+		ctx := queue.WithEnvelope(queue.WithQueueName(work, queueName), message.Envelope())
+		if message.Expired() {
+			log.Logger.Warnf("Skip expired request %s of type %s from %s", message.Id, message.MsgType, queueName)
+			return nil
 		}
-		//todo 按事件类型处理
-		switch message.MsgType {
+		if ok, err := claim(ctx, message); !ok {
+			return err
+		}
+
+		var err error
+		if !accepts(topic, message.MsgType) {
+			err = queue.NonRetryable(fmt.Errorf("message type %s is not accepted by queue %s", message.MsgType, queueName))
+		} else {
+			err = dispatch(ctx, message)
+		}
+		if err != nil {
+			// 释放占用，交还或者重新投递的消息才能再次处理
+			release(ctx, message)
+			if work.Err() != nil {
+				return fmt.Errorf("%w: %v", queue.ErrAborted, err)
+			}
+			return retryOrDeadLetter(ctx, driver, queueName, message, err)
+		}
+		done(ctx, message)
+		return nil
+	}
+
+	var err error
+	if bc, ok := driver.(queue.BatchConsumer); ok && topic.BatchSize > 1 {
+		wait := time.Duration(topic.BatchWait) * time.Millisecond
+		if wait <= 0 {
+			wait = defaultBatchWait
+		}
+		err = bc.ConsumeBatch(ctx, queueName, topic.BatchSize, wait, batchHandler(work, topic, driver, handler))
+	} else {
+		if topic.BatchSize > 1 {
+			log.Logger.Warnf("Queue driver does not support batch consume, consume %s one by one", queueName)
+		}
+		err = driver.Consume(ctx, queueName, handler)
+	}
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error consuming %s: %w", queueName, err)
+	}
+	return nil
+}
 
-		case _const.QUEUE_TEST:
-			return hd.handleTest(message)
+// batchHandler 按消息类型分组，注册了批量处理函数的类型整批处理，其余消息逐条交给 handler
+// 批量处理失败的消息逐条按重试策略处理，只有重新投递也失败的消息才算作失败
+func batchHandler(work context.Context, topic config.Topic, driver queue.Driver, handler queue.HandlerFunc) queue.BatchHandlerFunc {
+	// 按消息类型调用 queue.HandleBatch 注册的批量处理函数，外层包装 queue.UseBatch 注册的中间件
+	dispatch := queue.ChainBatch(queue.DispatchBatch)
+	return func(_ context.Context, messages []*queue.Message) error {
+		ctx := queue.WithQueueName(work, topic.Name)
+		failed := make(map[string]error)
+		groups := make(map[string][]*queue.Message)
+		var types []string
+		for _, message := range messages {
+			fn, ok := queue.BatchHandlerFor(message.MsgType)
+			if !ok || fn == nil || !accepts(topic, message.MsgType) {
+				if err := handler(ctx, message); err != nil {
+					failed[message.Id] = err
+				}
+				continue
+			}
+			if message.Expired() {
+				log.Logger.Warnf("Skip expired request %s of type %s from %s", message.Id, message.MsgType, topic.Name)
+				continue
+			}
+			if ok, err := claim(ctx, message); !ok {
+				if err != nil {
+					failed[message.Id] = err
+				}
+				continue
+			}
+			if _, ok = groups[message.MsgType]; !ok {
+				types = append(types, message.MsgType)
+			}
+			groups[message.MsgType] = append(groups[message.MsgType], message)
+		}
 
-		default:
-			log.Logger.Printf("Unknown message type: %s\n", message.MsgType)
+		for i, msgType := range types {
+			group := groups[msgType]
+			// 一批消息可能来自不同的请求，ctx 中是第一条消息的信封，需要逐条的追踪信息时使用 message.Envelope()
+			err := callBatch(queue.WithEnvelope(ctx, group[0].Envelope()), dispatch, group)
+			if err != nil && work.Err() != nil {
+				// 整批交还给队列，释放还没有处理完的消息的占用
+				for _, rest := range types[i:] {
+					for _, message := range groups[rest] {
+						release(ctx, message)
+					}
+				}
+				return fmt.Errorf("%w: %v", queue.ErrAborted, err)
+			}
+			for _, message := range group {
+				merr := queue.FailedIn(err, message)
+				if merr == nil {
+					done(ctx, message)
+					continue
+				}
+				release(ctx, message)
+				if rerr := retryOrDeadLetter(ctx, driver, topic.Name, message, merr); rerr != nil {
+					failed[message.Id] = rerr
+				}
+			}
+		}
+		if len(failed) > 0 {
+			if work.Err() != nil {
+				return fmt.Errorf("%w: %d messages unfinished", queue.ErrAborted, len(failed))
+			}
+			return &queue.BatchError{Errors: failed}
 		}
 		return nil
 	}
-	err := driver.Consume(ctx, queueName, handler)
+}
+
+// callBatch 调用批量处理函数，panic 视为整批失败
+func callBatch(ctx context.Context, fn queue.BatchHandlerFunc, messages []*queue.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Logger.Errorf("Handle %d messages of type %s panic: %v", len(messages), messages[0].MsgType, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx, messages)
+}
+
+// accepts 队列是否接受该消息类型
+func accepts(topic config.Topic, msgType string) bool {
+	return len(topic.MsgTypes) == 0 || slices.Contains(topic.MsgTypes, msgType)
+}
+
+// claim 占用消息，返回 false 时跳过处理：已经处理成功的消息直接确认
+// 仍被占用的消息 (可能是处理中途退出的消费者留下的) 返回 queue.ErrDedupBusy，不确认，等驱动在占用过期后重新投递
+// 去重存储不可用时继续处理，由 handler 自身保证幂等
+func claim(ctx context.Context, message *queue.Message) (bool, error) {
+	dedup, _ := queue.Dedup()
+	if dedup == nil || message.DedupKey() == "" {
+		return true, nil
+	}
+	result, err := dedup.Claim(ctx, message.DedupKey(), queue.DedupClaimTTL)
 	if err != nil {
-		log.Logger.Fatalf("Error consuming: %v\n", err)
+		log.Logger.Errorf("Claim dedup key %s failed: %v", message.DedupKey(), err)
+		return true, nil
+	}
+	switch result {
+	case queue.ClaimDone:
+		log.Logger.Infof("Skip duplicate message %s of type %s", message.DedupKey(), message.MsgType)
+		return false, nil
+	case queue.ClaimBusy:
+		log.Logger.Warnf("Message %s of type %s is claimed by another consumer, leave it for redelivery", message.DedupKey(), message.MsgType)
+		return false, queue.ErrDedupBusy
+	}
+	return true, nil
+}
+
+// done 记录消息处理成功
+func done(ctx context.Context, message *queue.Message) {
+	dedup, ttl := queue.Dedup()
+	if dedup == nil || message.DedupKey() == "" {
+		return
+	}
+	if err := dedup.Done(context.WithoutCancel(ctx), message.DedupKey(), ttl); err != nil {
+		log.Logger.Errorf("Mark dedup key %s failed: %v", message.DedupKey(), err)
+	}
+}
+
+// release 释放消息的占用，work 已经取消时也要释放
+func release(ctx context.Context, message *queue.Message) {
+	dedup, _ := queue.Dedup()
+	if dedup == nil || message.DedupKey() == "" {
+		return
+	}
+	if err := dedup.Release(context.WithoutCancel(ctx), message.DedupKey()); err != nil {
+		log.Logger.Errorf("Release dedup key %s failed: %v", message.DedupKey(), err)
 	}
 }
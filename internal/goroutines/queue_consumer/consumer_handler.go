@@ -1,17 +1,45 @@
 package queue_consumer
 
 import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_const "github.com/hhr0815hhr/gint/internal/const"
+	"github.com/hhr0815hhr/gint/internal/log"
 	"github.com/hhr0815hhr/gint/internal/queue"
 )
 
 type ConsumerHandler struct {
 }
 
-func commonRetry(message *queue.Message) error {
-	message.ReInCount++
-	return queue.PushQueue(message, queue.DefaultQueueName)
+var hd = &ConsumerHandler{}
+
+// 新增消息类型时在这里注册处理函数，Body 会自动解码为处理函数的参数类型
+func init() {
+	queue.Handle(_const.QUEUE_TEST, hd.handleTest)
+}
+
+// retryOrDeadLetter 按消息类型的重试策略延迟重新投递到原队列，重试次数用尽或不可重试时放入死信队列
+func retryOrDeadLetter(ctx context.Context, driver queue.Driver, queueName string, message *queue.Message, err error) error {
+	policy := queue.RetryPolicyFor(message.MsgType)
+	if policy.ShouldRetry(message.ReInCount, err) {
+		delay := policy.Backoff(message.ReInCount)
+		message.ReInCount++
+		log.Logger.Warnf("Message %s from %s failed, retry %d after %s: %v", message.MsgType, queueName, message.ReInCount, delay, err)
+		return driver.PublishDelayed(ctx, queueName, message, delay)
+	}
+
+	if message.Headers == nil {
+		message.Headers = make(map[string]interface{})
+	}
+	message.Headers[queue.HeaderLastError] = err.Error()
+	message.Headers[queue.HeaderFailedAt] = time.Now().Format(time.RFC3339)
+	message.Headers[queue.HeaderOriginalQueue] = queueName
+	log.Logger.Errorf("Message %s from %s failed after %d retries, move to %s: %v", message.MsgType, queueName, message.ReInCount, queue.DeadQueueName, err)
+	return driver.Publish(ctx, queue.DeadQueueName, message)
 }
 
-func (c *ConsumerHandler) handleTest(message *queue.Message) error {
+func (c *ConsumerHandler) handleTest(ctx context.Context, body gin.H) error {
 	return nil
 }
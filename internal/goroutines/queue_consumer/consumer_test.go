@@ -0,0 +1,106 @@
+package queue_consumer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/memory_queue"
+)
+
+// redeliverDriver 每隔 interval 投递一次 message，直到 handler 返回 nil，模拟可见性超时后的重新投递
+type redeliverDriver struct {
+	queue.Driver
+	message  *queue.Message
+	interval time.Duration
+	errs     chan error
+}
+
+func (d *redeliverDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	for {
+		err := handler(ctx, d.message)
+		d.errs <- err
+		if err == nil {
+			<-ctx.Done()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(d.interval):
+		}
+	}
+}
+
+var handled atomic.Int32
+
+func init() {
+	queue.Handle("test.dedup_claimed", func(ctx context.Context, body struct{}) error {
+		handled.Add(1)
+		return nil
+	})
+}
+
+func TestClaimedMessageIsRedelivered(t *testing.T) {
+	handled.Store(0)
+	store := memory_queue.NewDedupStore()
+	queue.SetDedupStore(store, 0)
+	defer queue.SetDedupStore(nil, 0)
+
+	message, err := queue.NewMessage("test.dedup_claimed", struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message.Id = "claimed-1"
+	// 另一个消费者占用后中途退出，占用在 100ms 后过期
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = store.Claim(ctx, message.DedupKey(), 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &redeliverDriver{Driver: memory_queue.NewInMemoryDriver(), message: message, interval: 40 * time.Millisecond, errs: make(chan error, 16)}
+	go Consumer(ctx, ctx, config.Topic{Name: "test.dedup_claimed"}, d)
+
+	if err = <-d.errs; !errors.Is(err, queue.ErrDedupBusy) {
+		t.Fatalf("first delivery err = %v, want ErrDedupBusy", err)
+	}
+	if n := handled.Load(); n != 0 {
+		t.Fatalf("handled = %d while claimed, want 0", n)
+	}
+	for {
+		select {
+		case err = <-d.errs:
+		case <-ctx.Done():
+			t.Fatal("message is not handled after the claim expired")
+		}
+		if err == nil {
+			break
+		}
+	}
+	if n := handled.Load(); n != 1 {
+		t.Fatalf("handled = %d, want 1", n)
+	}
+
+	// 处理成功后再次投递的消息直接确认，不再处理
+	if err = Consumer(context.Background(), context.Background(), config.Topic{Name: "test.dedup_claimed"}, &onceDriver{message: message}); err != nil {
+		t.Fatal(err)
+	}
+	if n := handled.Load(); n != 1 {
+		t.Fatalf("handled = %d after duplicate delivery, want 1", n)
+	}
+}
+
+// onceDriver 投递一次 message 后返回
+type onceDriver struct {
+	queue.Driver
+	message *queue.Message
+}
+
+func (d *onceDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	return handler(ctx, d.message)
+}
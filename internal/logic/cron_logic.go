@@ -0,0 +1,216 @@
+package logic
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/cache"
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/cron"
+	"github.com/hhr0815hhr/gint/internal/database"
+	"github.com/hhr0815hhr/gint/internal/database/model"
+	"github.com/hhr0815hhr/gint/internal/log"
+)
+
+const (
+	cronRunErrorSize  = 1000               // 与 CronRun.Error 的字段长度一致
+	cronRunRetention  = 7 * 24 * time.Hour // 默认的执行记录保留时间
+	cronRunPruneBatch = 1000               // 清理时每次删除的条数，避免长时间锁表
+)
+
+// CronJobInfo 定时任务的配置、调度信息和最近的执行记录
+type CronJobInfo struct {
+	Name     string          `json:"name"`
+	Spec     string          `json:"spec"`
+	Timeout  string          `json:"timeout,omitempty"`
+	Overlap  string          `json:"overlap"`
+	Lock     bool            `json:"lock"`
+	Disabled bool            `json:"disabled"`
+	Holder   string          `json:"holder,omitempty"` // 正在执行任务 (持有锁) 的节点
+	Nodes    []string        `json:"nodes"`            // 调度该任务的 gint cron 进程，为空时没有进程在调度
+	Next     *time.Time      `json:"next,omitempty"`
+	Prev     *time.Time      `json:"prev,omitempty"`
+	Runs     []model.CronRun `json:"runs"`
+}
+
+type CronLogic struct {
+	cronRunRepo     *model.CronRunRepo
+	cronSettingRepo *model.CronSettingRepo
+}
+
+var (
+	_ cron.Recorder      = (*CronLogic)(nil)
+	_ cron.SettingSource = (*CronLogic)(nil)
+)
+
+func NewCronLogic(cronRunRepo *model.CronRunRepo, cronSettingRepo *model.CronSettingRepo) *CronLogic {
+	return &CronLogic{
+		cronRunRepo:     cronRunRepo,
+		cronSettingRepo: cronSettingRepo,
+	}
+}
+
+// Source 按 config.Conf.Cron.Source 选择调度设置的来源
+func (l *CronLogic) Source() cron.SettingSource {
+	if config.Conf.Cron.Source == "db" {
+		return l
+	}
+	return cron.ConfigSource{}
+}
+
+// Settings 从 CronSetting 表加载调度设置
+func (l *CronLogic) Settings(ctx context.Context) (map[string]cron.Setting, error) {
+	var rows []model.CronSetting
+	if err := l.cronSettingRepo.Db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	settings := make(map[string]cron.Setting, len(rows))
+	for _, row := range rows {
+		settings[row.JobName] = cron.Setting{Spec: row.Spec, Disabled: row.Disabled}
+	}
+	return settings, nil
+}
+
+// Record 保存一次执行记录，作为调度器的 Recorder
+func (l *CronLogic) Record(ctx context.Context, run cron.Run) error {
+	errMsg := []rune(run.Error)
+	if len(errMsg) > cronRunErrorSize {
+		errMsg = errMsg[:cronRunErrorSize]
+	}
+	return l.cronRunRepo.Db.WithContext(ctx).Create(&model.CronRun{
+		JobName:    run.Job,
+		Host:       run.Host,
+		Status:     string(run.Status),
+		Error:      string(errMsg),
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		DurationMs: run.Duration().Milliseconds(),
+	}).Error
+}
+
+// Jobs 所有注册的任务和各自最近 limit 次执行记录
+func (l *CronLogic) Jobs(ctx context.Context, limit int) ([]CronJobInfo, error) {
+	var locker *cron.Locker
+	if cache.Client != nil {
+		locker = cron.NewLocker(cache.Client, cron.LockConfigOptions()...)
+	}
+	schedules := cron.Schedules(ctx, cache.Client)
+	names := make([]string, len(schedules))
+	for i, s := range schedules {
+		names[i] = s.Job.Name
+	}
+	runs, err := l.recentRuns(ctx, names, limit)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]CronJobInfo, 0, len(schedules))
+	for _, s := range schedules {
+		info := CronJobInfo{
+			Name:     s.Job.Name,
+			Spec:     s.Job.Spec,
+			Overlap:  string(s.Job.Overlap),
+			Lock:     s.Job.Lock,
+			Disabled: s.Disabled,
+			Nodes:    s.Nodes,
+			Runs:     runs[s.Job.Name],
+		}
+		if info.Nodes == nil {
+			info.Nodes = []string{}
+		}
+		if info.Runs == nil {
+			info.Runs = []model.CronRun{}
+		}
+		if s.Job.Timeout > 0 {
+			info.Timeout = s.Job.Timeout.String()
+		}
+		if !s.Next.IsZero() {
+			info.Next = &s.Next
+		}
+		if !s.Prev.IsZero() {
+			info.Prev = &s.Prev
+		}
+		if s.Job.Lock && locker != nil {
+			holder, _, err := locker.Holder(ctx, s.Job.Name)
+			if err != nil {
+				log.Logger.Errorf("Get lock holder of cron job %s failed: %v", s.Job.Name, err)
+			}
+			info.Holder = holder
+		}
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+// recentRuns 每个任务最近 limit 次执行记录，各任务的子查询都走 (job_name, started_at) 索引，合并成一次查询
+func (l *CronLogic) recentRuns(ctx context.Context, names []string, limit int) (map[string][]model.CronRun, error) {
+	runs := make(map[string][]model.CronRun, len(names))
+	if len(names) == 0 || limit <= 0 {
+		return runs, nil
+	}
+	db := l.cronRunRepo.Db.WithContext(ctx)
+	parts := make([]string, len(names))
+	args := make([]any, len(names))
+	for i, name := range names {
+		parts[i] = "(?)"
+		args[i] = db.Model(&model.CronRun{}).Where("job_name = ?", name).Order("started_at desc, id desc").Limit(limit)
+	}
+	var rows []model.CronRun
+	if err := db.Raw(strings.Join(parts, " UNION ALL "), args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	// UNION ALL 不保证各子查询的顺序，分组后重新排序
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].StartedAt.Equal(rows[j].StartedAt) {
+			return rows[i].StartedAt.After(rows[j].StartedAt)
+		}
+		return rows[i].Id > rows[j].Id
+	})
+	for _, row := range rows {
+		runs[row.JobName] = append(runs[row.JobName], row)
+	}
+	return runs, nil
+}
+
+// Runs 按时间倒序分页查询任务的执行记录
+func (l *CronLogic) Runs(name string, page, limit int) ([]model.CronRun, int64, error) {
+	runs, total, err := l.cronRunRepo.ForPage("", page, limit, "started_at desc, id desc", database.QueryCondition{
+		Field:    "job_name",
+		Operator: "=",
+		Value:    name,
+	})
+	if runs == nil {
+		runs = []model.CronRun{}
+	}
+	return runs, total, err
+}
+
+// Prune 删除超过 config.Conf.Cron.Retention 的执行记录，按任务分批删除，每批都走 (job_name, started_at) 索引
+func (l *CronLogic) Prune(ctx context.Context) error {
+	retention := time.Duration(config.Conf.Cron.Retention) * time.Second
+	if retention < 0 {
+		return nil
+	}
+	if retention == 0 {
+		retention = cronRunRetention
+	}
+	before := time.Now().Add(-retention)
+	db := l.cronRunRepo.Db.WithContext(ctx)
+	var names []string
+	if err := db.Model(&model.CronRun{}).Distinct("job_name").Pluck("job_name", &names).Error; err != nil {
+		return err
+	}
+	for _, name := range names {
+		for {
+			result := db.Where("job_name = ? AND started_at < ?", name, before).Limit(cronRunPruneBatch).Delete(&model.CronRun{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected < cronRunPruneBatch {
+				break
+			}
+		}
+	}
+	return nil
+}
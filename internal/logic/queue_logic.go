@@ -0,0 +1,24 @@
+package logic
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hhr0815hhr/gint/internal/cache"
+	"github.com/hhr0815hhr/gint/internal/queue/monitor"
+)
+
+type QueueLogic struct {
+}
+
+func NewQueueLogic() *QueueLogic {
+	return &QueueLogic{}
+}
+
+// Status 所有运行中的消费进程上报的状态
+func (l *QueueLogic) Status(ctx context.Context) ([]monitor.Status, error) {
+	if cache.Client == nil {
+		return nil, errors.New("redis is not initialized")
+	}
+	return monitor.List(ctx, cache.Client)
+}
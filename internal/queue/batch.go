@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchHandlerFunc 批量消息处理函数
+type BatchHandlerFunc func(ctx context.Context, messages []*Message) error
+
+// BatchPublisher 支持一次发布多条消息的驱动
+type BatchPublisher interface {
+	PublishBatch(ctx context.Context, queueName string, messages []*Message) error
+}
+
+// BatchConsumer 支持批量消费的驱动
+// 每次最多取 size 条消息，取到第一条之后最多再等待 wait，一批消息占用一个 worker
+type BatchConsumer interface {
+	ConsumeBatch(ctx context.Context, queueName string, size int, wait time.Duration, handler BatchHandlerFunc) error
+}
+
+// PublishBatch 批量发布，驱动不支持批量发布时逐条发布
+func PublishBatch(ctx context.Context, d Driver, queueName string, messages []*Message) error {
+	if bp, ok := d.(BatchPublisher); ok {
+		return bp.PublishBatch(ctx, queueName, messages)
+	}
+	for _, message := range messages {
+		if err := d.Publish(ctx, queueName, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PushQueueBatch 使用应用配置的驱动批量发布消息，ctx 的限制同 PushQueue
+func PushQueueBatch(msgs []*Message, queueName string) error {
+	return PushQueueBatchContext(context.Background(), msgs, queueName)
+}
+
+// PushQueueBatchContext 使用 ctx 批量发布消息
+func PushQueueBatchContext(ctx context.Context, msgs []*Message, queueName string) error {
+	return PublishBatch(ctx, driver(), queueName, msgs)
+}
+
+// BatchError 批量处理中部分消息失败，Errors 以消息 Id 为 key，不在其中的消息视为处理成功
+type BatchError struct {
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for id, err := range e.Errors {
+		parts = append(parts, id+": "+err.Error())
+	}
+	return fmt.Sprintf("%d messages failed: %s", len(e.Errors), strings.Join(parts, "; "))
+}
+
+// FailedIn 批量处理后 message 的错误，err 不是 BatchError 时所有消息都视为失败
+func FailedIn(err error, message *Message) error {
+	if err == nil {
+		return nil
+	}
+	var be *BatchError
+	if errors.As(err, &be) {
+		return be.Errors[message.Id]
+	}
+	return err
+}
+
+var (
+	batchHandlers   = make(map[string]BatchHandlerFunc)
+	batchHandlersMu sync.RWMutex
+)
+
+// HandleBatchMessages 注册消息类型的批量处理函数，队列开启批量消费时使用
+// 未注册批量处理函数的消息类型仍然逐条调用 Handle 注册的处理函数
+func HandleBatchMessages(msgType string, fn BatchHandlerFunc) {
+	batchHandlersMu.Lock()
+	defer batchHandlersMu.Unlock()
+	if _, ok := batchHandlers[msgType]; ok {
+		panic(fmt.Sprintf("queue: batch handler for message type %s already registered", msgType))
+	}
+	batchHandlers[msgType] = fn
+}
+
+// HandleBatch 注册批量处理函数，Body 会以 JSON 解码为 T，解码失败的消息不会重试，也不会传给 fn
+//
+//	queue.HandleBatch(_const.QUEUE_LOG, func(ctx context.Context, logs []model.Log) error {
+//		return repo.AddAll(toPtrs(logs))
+//	})
+func HandleBatch[T any](msgType string, fn func(ctx context.Context, bodies []T) error) {
+	HandleBatchMessages(msgType, func(ctx context.Context, messages []*Message) error {
+		failed := make(map[string]error)
+		bodies := make([]T, 0, len(messages))
+		decoded := make([]*Message, 0, len(messages))
+		for _, message := range messages {
+			body, err := Decode[T](message)
+			if err != nil {
+				failed[message.Id] = NonRetryable(fmt.Errorf("decode %s message body: %w", msgType, err))
+				continue
+			}
+			bodies = append(bodies, body)
+			decoded = append(decoded, message)
+		}
+		if len(bodies) > 0 {
+			if err := fn(ctx, bodies); err != nil {
+				for _, message := range decoded {
+					failed[message.Id] = err
+				}
+			}
+		}
+		if len(failed) > 0 {
+			return &BatchError{Errors: failed}
+		}
+		return nil
+	})
+}
+
+// DispatchBatch 调用消息类型对应的批量处理函数，同一批消息的类型相同
+func DispatchBatch(ctx context.Context, messages []*Message) error {
+	fn, ok := BatchHandlerFor(messages[0].MsgType)
+	if !ok || fn == nil {
+		return NonRetryable(fmt.Errorf("%w: %s", ErrUnknownMsgType, messages[0].MsgType))
+	}
+	return fn(ctx, messages)
+}
+
+// BatchHandlerFor 消息类型的批量处理函数
+func BatchHandlerFor(msgType string) (BatchHandlerFunc, bool) {
+	batchHandlersMu.RLock()
+	defer batchHandlersMu.RUnlock()
+	fn, ok := batchHandlers[msgType]
+	return fn, ok
+}
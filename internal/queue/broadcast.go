@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+)
+
+// ErrNoBroadcaster 没有配置广播
+var ErrNoBroadcaster = errors.New("queue: broadcaster is not configured")
+
+// Local 只在当前进程内可见的广播或队列驱动实现这个接口并返回 true，例如内存驱动
+// Request 据此判断回复能否送达
+type Local interface {
+	Local() bool
+}
+
+// isLocal v 是否只在当前进程内可见
+func isLocal(v any) bool {
+	l, ok := v.(Local)
+	return ok && l.Local()
+}
+
+const subscribeRetryDelay = time.Second // 订阅断开后重新订阅的间隔
+
+// Broadcaster 发布/订阅模式，与队列的竞争消费不同，每个订阅了 topic 的进程 (包括发布者自己) 都会收到每条消息
+// 广播不持久化、不重试，订阅之前或断线期间发布的消息会错过，适合缓存失效、配置变更通知这类消息
+type Broadcaster interface {
+	Broadcast(ctx context.Context, topic string, message *Message) error
+	// Subscribe 订阅 topic 直到 ctx 取消或连接断开，收到的消息依次交给 handler
+	Subscribe(ctx context.Context, topic string, handler HandlerFunc) error
+}
+
+var (
+	broadcaster Broadcaster
+	subscribers = make(map[string][]HandlerFunc)
+	broadcastMu sync.RWMutex
+)
+
+// SetBroadcaster 设置应用使用的广播实现
+func SetBroadcaster(b Broadcaster) {
+	broadcastMu.Lock()
+	defer broadcastMu.Unlock()
+	broadcaster = b
+}
+
+// currentBroadcaster 当前配置的广播，未配置时返回 nil
+func currentBroadcaster() Broadcaster {
+	broadcastMu.RLock()
+	defer broadcastMu.RUnlock()
+	return broadcaster
+}
+
+// Broadcast 向 topic 的所有订阅者发布消息
+func Broadcast(ctx context.Context, topic string, message *Message) error {
+	b := currentBroadcaster()
+	if b == nil {
+		return ErrNoBroadcaster
+	}
+	return b.Broadcast(ctx, topic, message)
+}
+
+// Subscribe 注册 topic 的订阅函数，一般在 init 中调用，StartSubscribers 时开始接收消息
+// 同一 topic 可以注册多个函数，每条消息按注册顺序依次调用
+func Subscribe(topic string, handler HandlerFunc) {
+	broadcastMu.Lock()
+	defer broadcastMu.Unlock()
+	subscribers[topic] = append(subscribers[topic], handler)
+}
+
+// StartSubscribers 为每个注册了订阅函数的 topic 启动订阅，ctx 取消时停止，断线后自动重新订阅
+func StartSubscribers(ctx context.Context) error {
+	broadcastMu.RLock()
+	defer broadcastMu.RUnlock()
+	if len(subscribers) == 0 {
+		return nil
+	}
+	if broadcaster == nil {
+		return ErrNoBroadcaster
+	}
+	for topic, handlers := range subscribers {
+		go subscribe(ctx, broadcaster, topic, handlers)
+	}
+	repliesStarted.Store(true)
+	return nil
+}
+
+// subscribe 保持 topic 的订阅，订阅函数的错误只记录日志
+func subscribe(ctx context.Context, b Broadcaster, topic string, handlers []HandlerFunc) {
+	handler := func(ctx context.Context, message *Message) error {
+		ctx = WithEnvelope(ctx, message.Envelope())
+		for _, h := range handlers {
+			if err := h(ctx, message); err != nil {
+				log.Logger.Errorf("Handle broadcast %s of topic %s failed: %v", message.Id, topic, err)
+			}
+		}
+		return nil
+	}
+	for {
+		err := b.Subscribe(ctx, topic, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Logger.Errorf("Subscription of topic %s lost, resubscribe after %s: %v", topic, subscribeRetryDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(subscribeRetryDelay):
+		}
+	}
+}
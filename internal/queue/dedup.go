@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDedupTTL = 24 * time.Hour
+	// DedupClaimTTL 处理中的占用记录的保留时间，消费者异常退出后消息在这之后才能被再次处理
+	DedupClaimTTL = 5 * time.Minute
+)
+
+// ClaimResult DedupStore.Claim 的结果
+type ClaimResult int
+
+const (
+	ClaimOK   ClaimResult = iota // 占用成功，继续处理
+	ClaimDone                    // 已经处理成功，跳过重复投递的消息
+	ClaimBusy                    // 其他消费者正在处理或者处理中途退出，占用过期前不能处理
+)
+
+// ErrDedupBusy 消息的去重 key 仍被占用，消费端不确认消息，由驱动在可见性超时后重新投递
+var ErrDedupBusy = errors.New("queue: dedup key is claimed by another consumer")
+
+// DedupStore 记录处理中和已经处理成功的消息，消费端据此跳过重复投递的消息
+// 消费端先 Claim 占用 key，处理成功后 Done，处理失败时 Release 让重试的消息可以再次处理
+type DedupStore interface {
+	// Claim 原子地占用 key，返回 key 处理中 (ClaimBusy) 或者已经处理成功 (ClaimDone)
+	Claim(ctx context.Context, key string, ttl time.Duration) (ClaimResult, error)
+	// Done 标记 key 对应的消息处理成功，ttl 后过期
+	Done(ctx context.Context, key string, ttl time.Duration) error
+	// Release 释放处理中的 key，已经处理成功的 key 不受影响
+	Release(ctx context.Context, key string) error
+}
+
+var (
+	dedupStore DedupStore
+	dedupTTL   = DefaultDedupTTL
+	dedupMu    sync.RWMutex
+)
+
+// SetDedupStore 开启消费端去重，store 为 nil 时关闭
+func SetDedupStore(store DedupStore, ttl time.Duration) {
+	dedupMu.Lock()
+	defer dedupMu.Unlock()
+	dedupStore = store
+	if ttl > 0 {
+		dedupTTL = ttl
+	}
+}
+
+// Dedup 当前的去重存储，未开启时返回 nil
+func Dedup() (DedupStore, time.Duration) {
+	dedupMu.RLock()
+	defer dedupMu.RUnlock()
+	return dedupStore, dedupTTL
+}
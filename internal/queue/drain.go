@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrAborted 进程退出时 handler 在等待时间内没有处理完成而被取消
+// 驱动不应确认返回这个错误的消息，而是通过 Requeuer 交还给队列，或者由持久化的记录在重启后恢复
+var ErrAborted = errors.New("queue: handler aborted by shutdown")
+
+// Requeuer 支持把 handler 返回 ErrAborted 的消息交还给队列的驱动，优雅退出时在取消 handler 之后调用
+// 不实现的驱动依赖自身的机制恢复这些消息，例如 stream 的 PEL、持久化内存队列的日志
+type Requeuer interface {
+	RequeueUnfinished(ctx context.Context) (int, error)
+}
+
+// Drain 等待所有队列正在处理的消息完成，ctx 结束时返回 ctx.Err()
+// 需要在所有消费者停止拉取之后调用
+func Drain(ctx context.Context) error {
+	poolsMu.Lock()
+	list := make([]*WorkerPool, 0, len(pools))
+	for _, p := range pools {
+		list = append(list, p)
+	}
+	poolsMu.Unlock()
+
+	for _, p := range list {
+		if err := p.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unfinished 记录 handler 返回 ErrAborted 的消息，供 RequeueUnfinished 交还给队列
+// 忽略取消仍在运行的 handler 不会被记录，交还之后不会与它重复处理，这些消息由驱动自身的机制恢复或者丢失
+type Unfinished[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+// Finish handler 返回后调用，err 为 ErrAborted 时记录消息并返回 true，驱动不应再确认这条消息
+func (u *Unfinished[T]) Finish(v T, err error) bool {
+	if !errors.Is(err, ErrAborted) {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.items = append(u.items, v)
+	return true
+}
+
+// Take 取出并清空所有被中断的消息
+func (u *Unfinished[T]) Take() []T {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	list := u.items
+	u.items = nil
+	return list
+}
@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUnfinished(t *testing.T) {
+	var u Unfinished[string]
+	if u.Finish("ok", nil) {
+		t.Fatal("Finish(nil) should not record the message")
+	}
+	if u.Finish("failed", errors.New("boom")) {
+		t.Fatal("Finish(err) should not record the message")
+	}
+	if !u.Finish("aborted", ErrAborted) {
+		t.Fatal("Finish(ErrAborted) should record the message")
+	}
+	if !u.Finish("wrapped", fmt.Errorf("%w: context canceled", ErrAborted)) {
+		t.Fatal("Finish(wrapped ErrAborted) should record the message")
+	}
+	if got := u.Take(); !slices.Equal(got, []string{"aborted", "wrapped"}) {
+		t.Fatalf("Take() = %v, want [aborted wrapped]", got)
+	}
+	if got := u.Take(); len(got) != 0 {
+		t.Fatalf("second Take() = %v, want empty", got)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	p := Pool("drain_test")
+	release := make(chan struct{})
+	if err := p.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain() with a running task = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := InFlight()["drain_test"]; n != 1 {
+		t.Fatalf("InFlight = %d, want 1", n)
+	}
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := Drain(ctx); err != nil {
+		t.Fatalf("Drain() after the task finished = %v, want nil", err)
+	}
+	if n := InFlight()["drain_test"]; n != 0 {
+		t.Fatalf("InFlight = %d, want 0", n)
+	}
+}
@@ -0,0 +1,114 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/cache"
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/database/mysql"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/memory_queue"
+	"github.com/hhr0815hhr/gint/internal/queue/mysql_queue"
+	"github.com/hhr0815hhr/gint/internal/queue/nats_queue"
+	"github.com/hhr0815hhr/gint/internal/queue/redis_queue"
+	"github.com/hhr0815hhr/gint/internal/queue/redis_stream"
+)
+
+const (
+	DriverMemory      = "memory"
+	DriverRedis       = "redis"
+	DriverRedisStream = "redis_stream"
+	DriverMysql       = "mysql"
+	DriverNats        = "nats"
+)
+
+// New 根据 config.Server.Queue 创建队列驱动，redis 相关驱动需要先初始化 cache.Client
+// mysql 驱动使用的表由 model 包自动迁移
+func New(name string) (queue.Driver, error) {
+	switch name {
+	case DriverMemory:
+		if dir := config.Conf.Queue.Memory.Dir; dir != "" {
+			d, err := memory_queue.NewDurableInMemoryDriver(dir, memory_queue.ConfigOptions()...)
+			if err != nil {
+				return nil, err
+			}
+			return d, nil
+		}
+		return memory_queue.NewInMemoryDriver(memory_queue.ConfigOptions()...), nil
+	case DriverRedis:
+		return redis_queue.NewRedisListDriver(cache.Client, config.Conf.Redis.Type, redis_queue.ConfigOptions()...), nil
+	case DriverRedisStream:
+		return redis_stream.NewRedisStreamDriver(cache.Client, config.Conf.Redis.Type, redis_stream.ConfigOptions()...), nil
+	case DriverMysql:
+		return mysql_queue.NewMysqlDriver(mysql.DB, mysql_queue.ConfigOptions()...), nil
+	case DriverNats:
+		conf := config.Conf.Queue.Nats
+		var (
+			d   *nats_queue.JetStreamDriver
+			err error
+		)
+		if conf.Embedded {
+			d, err = nats_queue.ConnectEmbedded(conf.StoreDir, nats_queue.ConfigOptions()...)
+		} else {
+			d, err = nats_queue.Connect(conf.Url, nats_queue.ConfigOptions()...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver: %s", name)
+	}
+}
+
+// NewDedupStore 创建与驱动匹配的消费端去重存储，nats 驱动的去重存储与 driver 共用连接
+// ttl 为处理成功的记录的保留时间，nats 驱动用它作为 KV bucket 的 TTL
+func NewDedupStore(name string, driver queue.Driver, ttl time.Duration) (queue.DedupStore, error) {
+	switch name {
+	case DriverMemory:
+		return memory_queue.NewDedupStore(), nil
+	case DriverMysql:
+		return mysql_queue.NewDedupStore(mysql.DB), nil
+	case DriverNats:
+		d, ok := driver.(*nats_queue.JetStreamDriver)
+		if !ok {
+			return nil, fmt.Errorf("queue driver %s is not a nats driver", name)
+		}
+		if d.Embedded() {
+			log.Logger.Warnf("Embedded nats-server only accepts in-process connections, dedup only covers consumers in this process")
+		}
+		return d.DedupStore(context.Background(), ttl)
+	case DriverRedis, DriverRedisStream:
+		return redis_queue.NewDedupStore(cache.Client), nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver: %s", name)
+	}
+}
+
+// NewBroadcaster 创建与驱动匹配的广播，nats 驱动的广播与 driver 共用连接
+func NewBroadcaster(name string, driver queue.Driver) (queue.Broadcaster, error) {
+	switch name {
+	case DriverMemory:
+		return memory_queue.NewBroadcaster(), nil
+	case DriverMysql:
+		// mysql 部署不一定有 Redis，只在进程内广播
+		log.Logger.Warnf("Queue driver %s has no cross-process broadcast, broadcasts only reach subscribers in this process", name)
+		return memory_queue.NewBroadcaster(), nil
+	case DriverNats:
+		d, ok := driver.(*nats_queue.JetStreamDriver)
+		if !ok {
+			return nil, fmt.Errorf("queue driver %s is not a nats driver", name)
+		}
+		if d.Embedded() {
+			log.Logger.Warnf("Embedded nats-server only accepts in-process connections, broadcasts only reach subscribers in this process")
+		}
+		return d.Broadcaster(), nil
+	case DriverRedis, DriverRedisStream:
+		return redis_queue.NewBroadcaster(cache.Client), nil
+	default:
+		return nil, fmt.Errorf("unknown queue driver: %s", name)
+	}
+}
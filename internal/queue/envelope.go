@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SchemaVersion 当前的消息信封版本，0 表示没有信封字段的旧消息
+const SchemaVersion = 1
+
+// Envelope 消息的信封信息，处理消息时可以通过 EnvelopeFrom(ctx) 获取
+type Envelope struct {
+	Id            string
+	CreatedAt     time.Time
+	Producer      string
+	SchemaVersion int
+	TraceId       string
+	CorrelationId string
+	Locale        string
+	Tenant        string
+}
+
+// Envelope 消息的信封信息
+func (m *Message) Envelope() Envelope {
+	return Envelope{
+		Id:            m.Id,
+		CreatedAt:     m.CreatedAt,
+		Producer:      m.Producer,
+		SchemaVersion: m.SchemaVersion,
+		TraceId:       m.TraceId,
+		CorrelationId: m.CorrelationId,
+		Locale:        m.Locale,
+		Tenant:        m.Tenant,
+	}
+}
+
+type envelopeKey struct{}
+
+// WithEnvelope 在 ctx 中保存信封信息
+// 发布端用来传递 TraceId、CorrelationId、Locale、Tenant，消费端由消费者在调用处理函数前设置
+func WithEnvelope(ctx context.Context, env Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, env)
+}
+
+// EnvelopeFrom 获取 ctx 中的信封信息
+func EnvelopeFrom(ctx context.Context) (Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return env, ok
+}
+
+var (
+	producer   string
+	producerMu sync.RWMutex
+)
+
+// SetProducer 设置发布消息的服务名称，默认为 "程序名@主机名"
+func SetProducer(name string) {
+	producerMu.Lock()
+	defer producerMu.Unlock()
+	producer = name
+}
+
+// Producer 发布消息的服务名称
+func Producer() string {
+	producerMu.RLock()
+	name := producer
+	producerMu.RUnlock()
+	if name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return filepath.Base(os.Args[0]) + "@" + host
+}
@@ -0,0 +1,18 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// NewId 生成一个随机的唯一标识 (32 位十六进制字符串)
+func NewId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// 随机数读取失败时退化为时间戳，保证不返回空值
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
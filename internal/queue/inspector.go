@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+var ErrMessageNotFound = errors.New("message not found")
+
+// Inspector 不消费消息的情况下查看和管理队列，用于死信队列的排查与重放
+type Inspector interface {
+	// Len 队列中的消息数量
+	Len(ctx context.Context, queueName string) (int64, error)
+	// List 分页查看队列中的消息 (不会移除消息)，每条保存的消息对应一条结果，无法解析的消息使用 DecodeStored 的占位消息
+	// 返回的数量少于 limit 表示已经到达末尾
+	List(ctx context.Context, queueName string, offset, limit int64) ([]*Message, error)
+	// Remove 删除指定 Id 的消息，消息不存在时返回 ErrMessageNotFound
+	Remove(ctx context.Context, queueName, id string) error
+	// Purge 清空队列，返回删除的消息数量
+	Purge(ctx context.Context, queueName string) (int64, error)
+}
+
+// DecodeStored 解析驱动中保存的消息，无法解析时返回占位消息，原始内容保存在 HeaderRawPayload 中
+// 占位消息的 Id 为 id，id 为空时由原始内容生成，Remove 可以按这个 Id 删除
+func DecodeStored(raw, id string) *Message {
+	m := &Message{}
+	err := json.Unmarshal([]byte(raw), m)
+	if err == nil {
+		return m
+	}
+	if id == "" {
+		sum := md5.Sum([]byte(raw))
+		id = "undecodable-" + hex.EncodeToString(sum[:8])
+	}
+	return &Message{
+		Id: id,
+		Headers: map[string]interface{}{
+			HeaderRawPayload: raw,
+			HeaderLastError:  err.Error(),
+		},
+	}
+}
+
+// IsUndecodable 是否是无法解析的消息，这类消息只能查看和删除，不能重放
+func IsUndecodable(m *Message) bool {
+	_, ok := m.Headers[HeaderRawPayload]
+	return ok
+}
+
+// inspectBatchSize 遍历队列时每次读取的消息数
+const inspectBatchSize = 100
+
+// Scan 遍历队列中满足 filter 的消息，fn 返回 false 时停止遍历
+func Scan(ctx context.Context, insp Inspector, queueName string, filter func(*Message) bool, fn func(*Message) bool) error {
+	var offset int64
+	for {
+		msgs, err := insp.List(ctx, queueName, offset, inspectBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if filter != nil && !filter(m) {
+				continue
+			}
+			if !fn(m) {
+				return nil
+			}
+		}
+		if len(msgs) < inspectBatchSize {
+			return nil
+		}
+		offset += int64(len(msgs))
+	}
+}
+
+// Find 按 Id 查找消息
+func Find(ctx context.Context, insp Inspector, queueName, id string) (*Message, error) {
+	var found *Message
+	err := Scan(ctx, insp, queueName, nil, func(m *Message) bool {
+		if m.Id == id {
+			found = m
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrMessageNotFound
+	}
+	return found, nil
+}
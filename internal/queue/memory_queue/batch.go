@@ -0,0 +1,87 @@
+package memory_queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+var (
+	_ queue.BatchPublisher = (*InMemoryDriver)(nil)
+	_ queue.BatchConsumer  = (*InMemoryDriver)(nil)
+)
+
+// PublishBatch 逐条放入队列，队列已满时按溢出策略处理
+func (d *InMemoryDriver) PublishBatch(ctx context.Context, queueName string, messages []*queue.Message) error {
+	for _, message := range messages {
+		if err := d.Publish(ctx, queueName, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeBatch 批量消费，阻塞等待第一条消息，之后在 wait 时间内凑满 size 条
+func (d *InMemoryDriver) ConsumeBatch(ctx context.Context, queueName string, size int, wait time.Duration, handler queue.BatchHandlerFunc) error {
+	q := d.getQueue(queueName)
+	pool := queue.Pool(queueName)
+	size = max(size, 1)
+	for {
+		// 一批消息占用一个 worker
+		if err := pool.Acquire(ctx); err != nil {
+			return err
+		}
+		first, err := q.pop(ctx)
+		if err != nil {
+			pool.Release()
+			return err
+		}
+		items := collect(ctx, q, first, size, wait)
+		pool.Go(func() {
+			messages := make([]*queue.Message, len(items))
+			for i, it := range items {
+				messages[i] = it.message
+			}
+			err := handler(ctx, messages)
+			if errors.Is(err, queue.ErrAborted) {
+				d.warnAborted(queueName, messages...)
+				return
+			}
+			if err != nil {
+				log.Logger.Errorf("Handle %d messages from %s failed: %v", len(messages), queueName, err)
+			}
+			// 只删除处理成功的消息，失败的消息保留在日志中
+			var seqs []uint64
+			for _, it := range items {
+				if queue.FailedIn(err, it.message) == nil {
+					seqs = append(seqs, it.seq)
+				}
+			}
+			d.forget(seqs...)
+		})
+	}
+}
+
+// collect 在 wait 时间内继续取消息直到凑满 size 条，ctx 取消时返回已经取到的消息
+func collect(ctx context.Context, q *memQueue, first *item, size int, wait time.Duration) []*item {
+	items := []*item{first}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for len(items) < size {
+		if it := q.tryPop(); it != nil {
+			items = append(items, it)
+			continue
+		}
+		select {
+		case <-q.notEmpty:
+		case <-timer.C:
+			return items
+		case <-ctx.Done():
+			return items
+		}
+	}
+	return items
+}
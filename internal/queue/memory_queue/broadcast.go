@@ -0,0 +1,84 @@
+package memory_queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+const subscriberBufferSize = 100
+
+var (
+	_ queue.Broadcaster = (*Broadcaster)(nil)
+	_ queue.Local       = (*Broadcaster)(nil)
+)
+
+// Broadcaster 进程内的广播，只有同一进程内的订阅者能收到消息
+// 每个订阅者有自己的缓冲区，处理太慢导致缓冲区已满时丢弃新消息，不会阻塞发布者
+type Broadcaster struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan *queue.Message]struct{}
+}
+
+// NewBroadcaster 创建进程内广播
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[string]map[chan *queue.Message]struct{})}
+}
+
+// Local 只有同一进程内的订阅者能收到消息
+func (b *Broadcaster) Local() bool {
+	return true
+}
+
+// Broadcast 把消息复制给 topic 当前的每个订阅者
+// 与其他驱动一样经过 JSON 编解码，每个订阅者拿到独立的 Body 和 Headers，修改不会互相影响
+func (b *Broadcaster) Broadcast(ctx context.Context, topic string, message *queue.Message) error {
+	message.Stamp(ctx)
+	payloadJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs[topic] {
+		m := &queue.Message{}
+		if err = json.Unmarshal(payloadJSON, m); err != nil {
+			return fmt.Errorf("failed to copy message: %w", err)
+		}
+		select {
+		case ch <- m:
+		default:
+			log.Logger.Warnf("Subscriber of topic %s is too slow, drop broadcast %s", topic, message.Id)
+		}
+	}
+	return nil
+}
+
+// Subscribe 订阅 topic 直到 ctx 取消
+func (b *Broadcaster) Subscribe(ctx context.Context, topic string, handler queue.HandlerFunc) error {
+	ch := make(chan *queue.Message, subscriberBufferSize)
+	b.mu.Lock()
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[chan *queue.Message]struct{})
+	}
+	b.subs[topic][ch] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs[topic], ch)
+		b.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message := <-ch:
+			_ = handler(ctx, message)
+		}
+	}
+}
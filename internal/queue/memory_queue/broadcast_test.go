@@ -0,0 +1,47 @@
+package memory_queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+func TestBroadcastCopiesMessage(t *testing.T) {
+	b := NewBroadcaster()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan *queue.Message, 2)
+	for i := 0; i < 2; i++ {
+		go b.Subscribe(ctx, "copy", func(ctx context.Context, m *queue.Message) error {
+			// 修改收到的消息不应影响其他订阅者和发布者
+			m.Body["name"] = "changed"
+			m.Headers["h"] = "changed"
+			received <- m
+			return nil
+		})
+	}
+	for {
+		b.mu.RLock()
+		n := len(b.subs["copy"])
+		b.mu.RUnlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	message := &queue.Message{MsgType: "test", Body: gin.H{"name": "origin"}, Headers: map[string]interface{}{"h": "origin"}}
+	if err := b.Broadcast(ctx, "copy", message); err != nil {
+		t.Fatal(err)
+	}
+	first, second := <-received, <-received
+	if first == second {
+		t.Fatal("subscribers share the same message")
+	}
+	if message.Body["name"] != "origin" || message.Headers["h"] != "origin" {
+		t.Fatalf("published message is modified: %+v", message)
+	}
+}
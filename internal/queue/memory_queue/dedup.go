@@ -0,0 +1,75 @@
+package memory_queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// DedupStore 内存去重存储，只在单进程内有效
+type DedupStore struct {
+	mu      sync.Mutex
+	entries map[string]dedupEntry
+	lastGC  time.Time
+}
+
+type dedupEntry struct {
+	done    bool
+	expires time.Time
+}
+
+var _ queue.DedupStore = (*DedupStore)(nil)
+
+// NewDedupStore 创建内存去重存储
+func NewDedupStore() *DedupStore {
+	return &DedupStore{entries: make(map[string]dedupEntry), lastGC: time.Now()}
+}
+
+// Claim 占用 key，顺带清理过期的 key
+func (s *DedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (queue.ClaimResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.gc(now)
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		if e.done {
+			return queue.ClaimDone, nil
+		}
+		return queue.ClaimBusy, nil
+	}
+	s.entries[key] = dedupEntry{expires: now.Add(ttl)}
+	return queue.ClaimOK, nil
+}
+
+// Done 标记 key 对应的消息处理成功
+func (s *DedupStore) Done(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = dedupEntry{done: true, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release 释放处理中的 key
+func (s *DedupStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && !e.done {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// gc 每分钟最多清理一次过期的 key
+func (s *DedupStore) gc(now time.Time) {
+	if now.Sub(s.lastGC) <= time.Minute {
+		return
+	}
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.lastGC = now
+}
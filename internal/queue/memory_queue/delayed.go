@@ -0,0 +1,102 @@
+package memory_queue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+)
+
+// delayedItem 一条等待投递的延迟消息
+type delayedItem struct {
+	at        time.Time
+	queueName string
+	it        *item
+}
+
+// delayedHeap 按投递时间排序的小顶堆
+type delayedHeap []*delayedItem
+
+func (h delayedHeap) Len() int            { return len(h) }
+func (h delayedHeap) Less(i, j int) bool  { return h[i].at.Before(h[j].at) }
+func (h delayedHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *delayedHeap) Push(x interface{}) { *h = append(*h, x.(*delayedItem)) }
+func (h *delayedHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// scheduleDelayed 加入延迟消息并唤醒调度协程
+func (d *InMemoryDriver) scheduleDelayed(item *delayedItem) {
+	d.delayedMu.Lock()
+	heap.Push(&d.delayed, item)
+	d.delayedMu.Unlock()
+	d.delayOnce.Do(func() {
+		go d.runDelayed()
+	})
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// runDelayed 等待堆顶消息到期后投递到对应队列
+func (d *InMemoryDriver) runDelayed() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		d.delayedMu.Lock()
+		now := time.Now()
+		var due []*delayedItem
+		for d.delayed.Len() > 0 && !d.delayed[0].at.After(now) {
+			due = append(due, heap.Pop(&d.delayed).(*delayedItem))
+		}
+		wait := time.Hour
+		if d.delayed.Len() > 0 {
+			wait = d.delayed[0].at.Sub(now)
+		}
+		d.delayedMu.Unlock()
+
+		for _, item := range due {
+			// 队列满时 Publish 会阻塞，不能卡住其他延迟消息的调度
+			go d.publishDue(item)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-d.wakeup:
+		case <-d.closed:
+			return
+		}
+	}
+}
+
+func (d *InMemoryDriver) publishDue(item *delayedItem) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	err := d.enqueue(ctx, item.queueName, item.it)
+	if errors.Is(err, ErrQueueFull) {
+		log.Logger.Errorf("Memory queue %s is full, drop delayed message %s", item.queueName, item.it.message.Id)
+		d.forget(item.it.seq)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package memory_queue
+
+import (
+	"os"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+)
+
+// lockDir 不支持 flock 的平台上不加锁，需要自行保证只有一个进程使用日志目录
+func lockDir(path string) (*os.File, error) {
+	log.Logger.Warnf("File lock is not supported, make sure only one process uses memory queue log %s", path)
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+}
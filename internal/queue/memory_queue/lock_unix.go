@@ -0,0 +1,26 @@
+//go:build unix
+
+package memory_queue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockDir 对日志目录加排他锁，已经被其他进程占用时立即返回错误，关闭返回的文件即释放锁
+func lockDir(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("memory queue log %s is used by another process, the durable memory driver is single-process", path)
+		}
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,218 @@
+package memory_queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// ErrQueueFull 队列已满且溢出策略为 OverflowReject
+var ErrQueueFull = errors.New("memory queue is full")
+
+// Overflow 队列已满时 push 的处理策略
+type Overflow string
+
+const (
+	OverflowBlock      Overflow = "block"       // 阻塞直到有空位 (默认)
+	OverflowDropOldest Overflow = "drop_oldest" // 丢弃最早入队的消息
+	OverflowReject     Overflow = "reject"      // 直接返回 ErrQueueFull
+)
+
+// item 队列中的一条消息，seq 为驱动内单调递增的序号，持久化模式下用来对应日志记录
+type item struct {
+	seq     uint64
+	message *queue.Message
+}
+
+// memQueue 单个内存队列，按优先级分为多个 FIFO 通道，所有通道共享容量
+// 使用切片而不是 channel，便于在不消费的情况下查看和删除消息
+type memQueue struct {
+	mu       sync.Mutex
+	lanes    [queue.LaneCount][]*item
+	count    int
+	size     int
+	overflow Overflow
+	sched    *queue.LaneScheduler
+	notEmpty chan struct{}
+	notFull  chan struct{}
+}
+
+func newMemQueue(size int, overflow Overflow) *memQueue {
+	return &memQueue{
+		size:     size,
+		overflow: overflow,
+		sched:    queue.NewLaneScheduler(),
+		notEmpty: make(chan struct{}, 1),
+		notFull:  make(chan struct{}, 1),
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// push 入队，队列已满时按溢出策略处理，丢弃最早的消息时返回被丢弃的消息
+func (q *memQueue) push(ctx context.Context, it *item) (*item, error) {
+	lane := queue.Lane(it.message.Priority)
+	for {
+		q.mu.Lock()
+		var dropped *item
+		if q.count >= q.size {
+			switch q.overflow {
+			case OverflowReject:
+				q.mu.Unlock()
+				return nil, ErrQueueFull
+			case OverflowDropOldest:
+				dropped = q.dropOldest()
+			}
+		}
+		if q.count < q.size {
+			q.lanes[lane] = append(q.lanes[lane], it)
+			q.count++
+			if q.count < q.size {
+				signal(q.notFull)
+			}
+			q.mu.Unlock()
+			signal(q.notEmpty)
+			return dropped, nil
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.notFull:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// restore 恢复持久化的消息，不受容量限制
+func (q *memQueue) restore(it *item) {
+	q.mu.Lock()
+	lane := queue.Lane(it.message.Priority)
+	q.lanes[lane] = append(q.lanes[lane], it)
+	q.count++
+	q.mu.Unlock()
+	signal(q.notEmpty)
+}
+
+// dropOldest 删除所有通道中序号最小的消息，调用方需持有锁
+func (q *memQueue) dropOldest() *item {
+	oldest := -1
+	for lane, items := range q.lanes {
+		if len(items) > 0 && (oldest < 0 || items[0].seq < q.lanes[oldest][0].seq) {
+			oldest = lane
+		}
+	}
+	if oldest < 0 {
+		return nil
+	}
+	it := q.lanes[oldest][0]
+	q.lanes[oldest][0] = nil
+	q.lanes[oldest] = q.lanes[oldest][1:]
+	q.count--
+	return it
+}
+
+// pop 按通道顺序出队，队列为空时阻塞直到有消息或 ctx 取消
+func (q *memQueue) pop(ctx context.Context) (*item, error) {
+	for {
+		if it := q.tryPop(); it != nil {
+			return it, nil
+		}
+		select {
+		case <-q.notEmpty:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryPop 按通道顺序出队，队列为空时返回 nil
+func (q *memQueue) tryPop() *item {
+	q.mu.Lock()
+	if q.count == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	var it *item
+	for _, lane := range q.sched.Order() {
+		if items := q.lanes[lane]; len(items) > 0 {
+			it = items[0]
+			items[0] = nil
+			q.lanes[lane] = items[1:]
+			break
+		}
+	}
+	q.count--
+	if q.count > 0 {
+		signal(q.notEmpty)
+	}
+	q.mu.Unlock()
+	signal(q.notFull)
+	return it
+}
+
+func (q *memQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
+
+// slice 按优先级从高到低复制 [offset, offset+limit) 范围内的消息
+func (q *memQueue) slice(offset, limit int) []*queue.Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var msgs []*queue.Message
+	for _, items := range q.lanes {
+		if limit <= 0 {
+			break
+		}
+		if offset >= len(items) {
+			offset -= len(items)
+			continue
+		}
+		end := min(offset+limit, len(items))
+		for _, it := range items[offset:end] {
+			msgs = append(msgs, it.message)
+		}
+		limit -= end - offset
+		offset = 0
+	}
+	return msgs
+}
+
+// remove 删除指定 Id 的消息
+func (q *memQueue) remove(id string) *item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for lane, items := range q.lanes {
+		for i, it := range items {
+			if it.message.Id == id {
+				q.lanes[lane] = append(items[:i], items[i+1:]...)
+				q.count--
+				signal(q.notFull)
+				return it
+			}
+		}
+	}
+	return nil
+}
+
+// purge 清空队列，返回删除的消息
+func (q *memQueue) purge() []*item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := make([]*item, 0, q.count)
+	for _, lane := range q.lanes {
+		items = append(items, lane...)
+	}
+	q.lanes = [queue.LaneCount][]*item{}
+	q.count = 0
+	signal(q.notFull)
+	return items
+}
@@ -2,63 +2,342 @@ package memory_queue
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/log"
 	"github.com/hhr0815hhr/gint/internal/queue" // 替换为你的模块路径
 )
 
-// InMemoryDriver 使用内存 Channel 实现的队列驱动
+const (
+	defaultBufferSize      = 100
+	defaultCompactInterval = time.Minute
+)
+
+var (
+	_ queue.Inspector = (*InMemoryDriver)(nil)
+	_ queue.Local     = (*InMemoryDriver)(nil)
+)
+
+// InMemoryDriver 使用内存实现的队列驱动
+// 开启持久化 (NewDurableInMemoryDriver) 后未处理完成的消息会写入本地追加日志，重启后恢复
 type InMemoryDriver struct {
-	queues map[string]chan *queue.Message
+	queues map[string]*memQueue
 	mu     sync.Mutex
+	seq    atomic.Uint64
+
+	bufferSize int
+	overflow   Overflow
+
+	// 持久化模式
+	log             *appendLog
+	compactInterval time.Duration
+
+	// 延迟消息
+	delayed   delayedHeap
+	delayedMu sync.Mutex
+	delayOnce sync.Once
+	wakeup    chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Option InMemoryDriver 的可选配置
+type Option func(d *InMemoryDriver)
+
+// WithBufferSize 每个队列最多缓存的消息数
+func WithBufferSize(size int) Option {
+	return func(d *InMemoryDriver) {
+		d.bufferSize = size
+	}
+}
+
+// WithOverflow 队列已满时的处理策略
+func WithOverflow(overflow Overflow) Option {
+	return func(d *InMemoryDriver) {
+		d.overflow = overflow
+	}
+}
+
+// WithCompactInterval 持久化模式下压缩日志的间隔
+func WithCompactInterval(interval time.Duration) Option {
+	return func(d *InMemoryDriver) {
+		d.compactInterval = interval
+	}
+}
+
+// ConfigOptions 根据 config.Conf.Queue.Memory 生成驱动配置
+func ConfigOptions() []Option {
+	conf := config.Conf.Queue.Memory
+	return []Option{
+		WithBufferSize(conf.BufferSize),
+		WithOverflow(Overflow(conf.Overflow)),
+		WithCompactInterval(time.Duration(conf.CompactInterval) * time.Second),
+	}
 }
 
 // NewInMemoryDriver 创建一个新的内存队列驱动
-func NewInMemoryDriver() *InMemoryDriver {
-	return &InMemoryDriver{
-		queues: make(map[string]chan *queue.Message),
+func NewInMemoryDriver(opts ...Option) *InMemoryDriver {
+	d := &InMemoryDriver{
+		queues: make(map[string]*memQueue),
+		wakeup: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.bufferSize <= 0 {
+		d.bufferSize = defaultBufferSize
 	}
+	switch d.overflow {
+	case OverflowBlock, OverflowDropOldest, OverflowReject:
+	default:
+		d.overflow = OverflowBlock
+	}
+	if d.compactInterval <= 0 {
+		d.compactInterval = defaultCompactInterval
+	}
+	return d
 }
 
-// Publish 将消息发布到内存队列
-func (d *InMemoryDriver) Publish(ctx context.Context, queueName string, message *queue.Message) error {
+// NewDurableInMemoryDriver 创建持久化的内存队列驱动，dir 为日志目录
+// 启动时回放日志恢复未处理完成的消息 (包括未到期的延迟消息)，消息在 handler 成功返回后才从日志中删除
+// 日志目录只能由一个进程使用，serve、consumer 和 queue 命令不能同时打开同一个目录，已被占用时返回错误
+func NewDurableInMemoryDriver(dir string, opts ...Option) (*InMemoryDriver, error) {
+	d := NewInMemoryDriver(opts...)
+	l, records, err := openLog(dir)
+	if err != nil {
+		return nil, err
+	}
+	d.log = l
+	now := time.Now()
+	for _, rec := range records {
+		message := &queue.Message{}
+		if err = json.Unmarshal(rec.Msg, message); err != nil {
+			log.Logger.Warnf("Skip broken memory queue message %d: %v", rec.Seq, err)
+			d.forget(rec.Seq)
+			continue
+		}
+		it := &item{seq: rec.Seq, message: message}
+		if at := time.UnixMilli(rec.At); rec.At > 0 && at.After(now) {
+			d.scheduleDelayed(&delayedItem{at: at, queueName: rec.Queue, it: it})
+		} else {
+			d.getQueue(rec.Queue).restore(it)
+		}
+		d.seq.Store(max(d.seq.Load(), rec.Seq))
+	}
+	if len(records) > 0 {
+		log.Logger.Printf("Restored %d messages from memory queue log %s", len(records), l.path)
+	}
+	go d.compactLoop()
+	return d, nil
+}
+
+// getQueue 获取队列，不存在时创建
+func (d *InMemoryDriver) getQueue(queueName string) *memQueue {
 	d.mu.Lock()
+	defer d.mu.Unlock()
 	q, ok := d.queues[queueName]
 	if !ok {
-		q = make(chan *queue.Message, 100) // 可配置缓冲区大小
+		q = newMemQueue(d.bufferSize, d.overflow)
 		d.queues[queueName] = q
 	}
-	d.mu.Unlock()
+	return q
+}
 
-	select {
-	case q <- message:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+// newItem 分配序号，持久化模式下先写日志
+func (d *InMemoryDriver) newItem(ctx context.Context, queueName string, message *queue.Message, at time.Time) (*item, error) {
+	message.Stamp(ctx)
+	it := &item{seq: d.seq.Add(1), message: message}
+	if d.log != nil {
+		var ms int64
+		if !at.IsZero() {
+			ms = at.UnixMilli()
+		}
+		if err := d.log.put(it.seq, queueName, message, ms); err != nil {
+			return nil, err
+		}
 	}
+	return it, nil
 }
 
-// Consume 从内存队列消费消息
-func (d *InMemoryDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
-	d.mu.Lock()
-	q, ok := d.queues[queueName]
-	if !ok {
-		q = make(chan *queue.Message, 100) // 确保队列存在
-		d.queues[queueName] = q
+// enqueue 放入队列，溢出丢弃的消息同时从日志中删除
+func (d *InMemoryDriver) enqueue(ctx context.Context, queueName string, it *item) error {
+	dropped, err := d.getQueue(queueName).push(ctx, it)
+	if err != nil {
+		return err
+	}
+	if dropped != nil {
+		log.Logger.Warnf("Memory queue %s is full, drop oldest message %s", queueName, dropped.message.Id)
+		d.forget(dropped.seq)
 	}
-	d.mu.Unlock()
+	return nil
+}
+
+// forget 消息不再需要恢复，从日志中删除
+func (d *InMemoryDriver) forget(seqs ...uint64) {
+	if d.log == nil || len(seqs) == 0 {
+		return
+	}
+	if err := d.log.del(seqs...); err != nil && !errors.Is(err, errLogClosed) {
+		log.Logger.Errorf("Write memory queue log failed: %v", err)
+	}
+}
 
+// compactLoop 定期压缩日志
+func (d *InMemoryDriver) compactLoop() {
+	ticker := time.NewTicker(d.compactInterval)
+	defer ticker.Stop()
 	for {
 		select {
-		case msg := <-q:
-			go handler(ctx, msg)
-		case <-ctx.Done():
-			return ctx.Err()
+		case <-ticker.C:
+			if err := d.log.compact(); err != nil {
+				log.Logger.Errorf("Compact memory queue log failed: %v", err)
+			}
+		case <-d.closed:
+			return
 		}
 	}
 }
 
-// Close 关闭内存队列 (对于内存队列，通常不需要显式关闭)
-func (d *InMemoryDriver) Close() error {
+// Publish 将消息发布到内存队列
+func (d *InMemoryDriver) Publish(ctx context.Context, queueName string, message *queue.Message) error {
+	it, err := d.newItem(ctx, queueName, message, time.Time{})
+	if err != nil {
+		return err
+	}
+	if err = d.enqueue(ctx, queueName, it); err != nil {
+		d.forget(it.seq)
+		return err
+	}
+	return nil
+}
+
+// PublishDelayed 延迟投递，消息保存在按时间排序的堆中，到期后放入队列
+func (d *InMemoryDriver) PublishDelayed(ctx context.Context, queueName string, message *queue.Message, delay time.Duration) error {
+	return d.PublishAt(ctx, queueName, message, time.Now().Add(delay))
+}
+
+// PublishAt 在指定时间投递
+func (d *InMemoryDriver) PublishAt(ctx context.Context, queueName string, message *queue.Message, at time.Time) error {
+	if !at.After(time.Now()) {
+		return d.Publish(ctx, queueName, message)
+	}
+	it, err := d.newItem(ctx, queueName, message, at)
+	if err != nil {
+		return err
+	}
+	d.scheduleDelayed(&delayedItem{at: at, queueName: queueName, it: it})
+	return nil
+}
+
+// Consume 从内存队列消费消息
+func (d *InMemoryDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	q := d.getQueue(queueName) // 确保队列存在
+	pool := queue.Pool(queueName)
+
+	for {
+		// 没有空闲 worker 时不再取消息
+		if err := pool.Acquire(ctx); err != nil {
+			return err
+		}
+		it, err := q.pop(ctx)
+		if err != nil {
+			pool.Release()
+			return err
+		}
+		pool.Go(func() {
+			// 退出时被中断的消息和重新投递失败的消息保留在日志中，重启后恢复
+			// 失败重试会以新消息重新发布，handler 成功返回时才可以删除
+			if err := handler(ctx, it.message); err != nil {
+				if errors.Is(err, queue.ErrAborted) {
+					d.warnAborted(queueName, it.message)
+					return
+				}
+				if d.log != nil {
+					log.Logger.Errorf("Memory queue message %s is not republished, keep it in the log until restart: %v", it.message.Id, err)
+				}
+				return
+			}
+			d.forget(it.seq)
+		})
+	}
+}
+
+// Local 内存队列只在当前进程内可见
+func (d *InMemoryDriver) Local() bool {
+	return true
+}
+
+// Len 队列中的消息数量 (不包含未到期的延迟消息)
+func (d *InMemoryDriver) Len(ctx context.Context, queueName string) (int64, error) {
+	return int64(d.getQueue(queueName).len()), nil
+}
+
+// List 分页查看队列中的消息，按入队顺序排列
+func (d *InMemoryDriver) List(ctx context.Context, queueName string, offset, limit int64) ([]*queue.Message, error) {
+	return d.getQueue(queueName).slice(int(offset), int(limit)), nil
+}
+
+// Remove 删除指定 Id 的消息
+func (d *InMemoryDriver) Remove(ctx context.Context, queueName, id string) error {
+	it := d.getQueue(queueName).remove(id)
+	if it == nil {
+		return queue.ErrMessageNotFound
+	}
+	d.forget(it.seq)
 	return nil
 }
+
+// Purge 清空队列
+func (d *InMemoryDriver) Purge(ctx context.Context, queueName string) (int64, error) {
+	items := d.getQueue(queueName).purge()
+	seqs := make([]uint64, len(items))
+	for i, it := range items {
+		seqs[i] = it.seq
+	}
+	d.forget(seqs...)
+	return int64(len(items)), nil
+}
+
+// Close 关闭内存队列，停止延迟消息的调度，持久化模式下压缩并关闭日志，未处理完成的消息重启后恢复
+func (d *InMemoryDriver) Close() error {
+	var err error
+	d.closeOnce.Do(func() {
+		close(d.closed)
+		if d.log == nil {
+			d.warnLost()
+			return
+		}
+		if err = d.log.compact(); err != nil {
+			log.Logger.Errorf("Compact memory queue log failed: %v", err)
+		}
+		err = d.log.close()
+	})
+	return err
+}
+
+// warnAborted 未开启持久化时，被中断的消息没有地方可以交还，记录为丢失
+func (d *InMemoryDriver) warnAborted(queueName string, messages ...*queue.Message) {
+	if d.log != nil {
+		return
+	}
+	for _, message := range messages {
+		log.Logger.Warnf("Memory queue %s is not durable, aborted message %s of type %s is lost", queueName, message.Id, message.MsgType)
+	}
+}
+
+// warnLost 未开启持久化时，关闭后队列中剩余的消息会丢失
+func (d *InMemoryDriver) warnLost() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, q := range d.queues {
+		if n := q.len(); n > 0 {
+			log.Logger.Warnf("Memory queue %s is not durable, %d pending messages are lost", name, n)
+		}
+	}
+}
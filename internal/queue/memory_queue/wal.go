@@ -0,0 +1,247 @@
+package memory_queue
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+const (
+	logFileName  = "queue.log"
+	lockFileName = "queue.lock"
+)
+
+var errLogClosed = errors.New("memory queue log is closed")
+
+// 日志记录类型
+const (
+	opPut = "put" // 写入一条消息
+	opDel = "del" // 消息已处理、被删除或被丢弃
+)
+
+// logRecord 追加日志中的一行 (JSON)
+type logRecord struct {
+	Op    string          `json:"op"`
+	Seq   uint64          `json:"seq"`
+	Queue string          `json:"queue,omitempty"`
+	At    int64           `json:"at,omitempty"` // 延迟消息的投递时间 (毫秒时间戳)，0 表示立即投递
+	Msg   json.RawMessage `json:"msg,omitempty"`
+}
+
+// appendLog 持久化模式下的追加日志，记录尚未处理完成的消息
+// 每条记录写入后立即 write 到文件 (进程崩溃不丢失)，只在压缩和关闭时 fsync
+// 日志目录由打开它的进程独占，直到 close
+type appendLog struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	lock   *os.File
+	live   map[uint64]*logRecord // 仍然有效的 put 记录
+	dead   int                   // 已失效的记录数，为 0 时不需要压缩
+	closed bool
+}
+
+// openLog 锁定 dir 后打开其中的日志文件并回放，返回仍然有效的记录 (按 seq 排序)
+// dir 已经被其他进程打开时返回错误
+func openLog(dir string) (*appendLog, []*logRecord, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	lock, err := lockDir(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return nil, nil, err
+	}
+	l := &appendLog{
+		path: filepath.Join(dir, logFileName),
+		lock: lock,
+		live: make(map[uint64]*logRecord),
+	}
+	if err = l.replay(); err != nil {
+		_ = lock.Close()
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		_ = lock.Close()
+		return nil, nil, err
+	}
+	l.file = file
+	return l, l.sorted(), nil
+}
+
+// replay 读取日志重建有效记录，跳过无法解析的行
+// 进程崩溃时最后一行可能不完整，截断到最后一个完整的行，避免之后追加的记录与其拼在一起
+func (l *appendLog) replay() error {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var valid int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			if len(data) > 0 {
+				log.Logger.Warnf("Truncate incomplete memory queue log line %d", line)
+				return os.Truncate(l.path, valid)
+			}
+			return nil
+		}
+		valid += int64(len(data))
+		rec := &logRecord{}
+		if err = json.Unmarshal(data, rec); err != nil {
+			log.Logger.Warnf("Skip broken memory queue log line %d: %v", line, err)
+			continue
+		}
+		l.apply(rec)
+	}
+}
+
+func (l *appendLog) apply(rec *logRecord) {
+	switch rec.Op {
+	case opPut:
+		l.live[rec.Seq] = rec
+	case opDel:
+		// del 记录本身和被删除的 put 记录在压缩后都不再需要
+		if _, ok := l.live[rec.Seq]; ok {
+			delete(l.live, rec.Seq)
+			l.dead++
+		}
+		l.dead++
+	}
+}
+
+func (l *appendLog) sorted() []*logRecord {
+	records := make([]*logRecord, 0, len(l.live))
+	for _, rec := range l.live {
+		records = append(records, rec)
+	}
+	slices.SortFunc(records, func(a, b *logRecord) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	return records
+}
+
+// put 记录一条新消息
+func (l *appendLog) put(seq uint64, queueName string, message *queue.Message, at int64) error {
+	msg, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return l.write(&logRecord{Op: opPut, Seq: seq, Queue: queueName, At: at, Msg: msg})
+}
+
+// del 记录消息已经不需要恢复
+func (l *appendLog) del(seqs ...uint64) error {
+	recs := make([]*logRecord, len(seqs))
+	for i, seq := range seqs {
+		recs[i] = &logRecord{Op: opDel, Seq: seq}
+	}
+	return l.write(recs...)
+}
+
+func (l *appendLog) write(recs ...*logRecord) error {
+	var buf []byte
+	for _, rec := range recs {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errLogClosed
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		l.apply(rec)
+	}
+	return nil
+}
+
+// compact 只保留有效记录重写日志，先写临时文件再 rename 替换，保证任意时刻崩溃都有完整的日志
+func (l *appendLog) compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed || l.dead == 0 {
+		return nil
+	}
+	tmp := l.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, rec := range l.sorted() {
+		if err = enc.Encode(rec); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	// rename 之后旧的文件句柄指向已删除的文件，需要重新打开
+	file, err = os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_ = l.file.Close()
+	l.file = file
+	l.dead = 0
+	return nil
+}
+
+// close 刷盘并关闭日志，释放目录锁，之后的写入返回 errLogClosed
+func (l *appendLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	err := l.file.Sync()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := l.lock.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
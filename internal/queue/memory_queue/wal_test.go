@@ -0,0 +1,197 @@
+package memory_queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+func openTestLog(t *testing.T, dir string) (*appendLog, []*logRecord) {
+	t.Helper()
+	l, records, err := openLog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, records
+}
+
+func putMessages(t *testing.T, l *appendLog, seqs ...uint64) {
+	t.Helper()
+	for _, seq := range seqs {
+		if err := l.put(seq, "test", &queue.Message{Id: string(rune('a' + seq))}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func seqsOf(records []*logRecord) []uint64 {
+	seqs := make([]uint64, len(records))
+	for i, rec := range records {
+		seqs[i] = rec.Seq
+	}
+	return seqs
+}
+
+func TestLogReplay(t *testing.T) {
+	dir := t.TempDir()
+	l, records := openTestLog(t, dir)
+	if len(records) != 0 {
+		t.Fatalf("records = %d, want 0", len(records))
+	}
+	putMessages(t, l, 3, 1, 2)
+	if err := l.del(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, records = openTestLog(t, dir)
+	defer l.close()
+	if got := seqsOf(records); !slices.Equal(got, []uint64{1, 3}) {
+		t.Fatalf("seqs = %v, want [1 3]", got)
+	}
+	if records[0].Queue != "test" || !bytes.Contains(records[0].Msg, []byte(`"b"`)) {
+		t.Fatalf("record = %+v", records[0])
+	}
+}
+
+func TestLogTruncateTornLine(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	putMessages(t, l, 1, 2)
+	if err := l.close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, logFileName)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟写入一半时进程崩溃
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString(`{"op":"put","seq":3,"que`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	l, records := openTestLog(t, dir)
+	if got := seqsOf(records); !slices.Equal(got, []uint64{1, 2}) {
+		t.Fatalf("seqs = %v, want [1 2]", got)
+	}
+	if after, _ := os.Stat(path); after.Size() != info.Size() {
+		t.Fatalf("size = %d, want %d", after.Size(), info.Size())
+	}
+	// 截断后追加的记录可以正常回放
+	putMessages(t, l, 3)
+	if err = l.close(); err != nil {
+		t.Fatal(err)
+	}
+	l, records = openTestLog(t, dir)
+	defer l.close()
+	if got := seqsOf(records); !slices.Equal(got, []uint64{1, 2, 3}) {
+		t.Fatalf("seqs = %v, want [1 2 3]", got)
+	}
+}
+
+func TestLogCompact(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	putMessages(t, l, 1, 2, 3)
+	if err := l.del(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.compact(); err != nil {
+		t.Fatal(err)
+	}
+	if l.dead != 0 {
+		t.Fatalf("dead = %d, want 0", l.dead)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, logFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 1 {
+		t.Fatalf("lines = %d, want 1", n)
+	}
+	// 压缩后继续写入新的文件
+	putMessages(t, l, 4)
+	if err = l.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, records := openTestLog(t, dir)
+	defer l.close()
+	if got := seqsOf(records); !slices.Equal(got, []uint64{2, 4}) {
+		t.Fatalf("seqs = %v, want [2 4]", got)
+	}
+}
+
+func TestLogLocked(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := openTestLog(t, dir)
+	if _, _, err := openLog(dir); err == nil {
+		t.Fatal("open a locked log dir should fail")
+	}
+	if err := l.close(); err != nil {
+		t.Fatal(err)
+	}
+	l, _ = openTestLog(t, dir)
+	l.close()
+}
+
+func TestDurableKeepsFailedMessage(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDurableInMemoryDriver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = d.Publish(ctx, "wal_failed", &queue.Message{Id: "failed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Publish(ctx, "wal_failed", &queue.Message{Id: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan struct{}, 2)
+	go d.Consume(ctx, "wal_failed", func(ctx context.Context, m *queue.Message) error {
+		defer func() { handled <- struct{}{} }()
+		if m.Id == "failed" {
+			return errors.New("republish failed")
+		}
+		return nil
+	})
+	for range 2 {
+		select {
+		case <-handled:
+		case <-ctx.Done():
+			t.Fatal("timeout")
+		}
+	}
+	// 等待 handler 返回后的日志写入
+	time.Sleep(50 * time.Millisecond)
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = NewDurableInMemoryDriver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	msgs, _ := d.List(ctx, "wal_failed", 0, 10)
+	if len(msgs) != 1 || msgs[0].Id != "failed" {
+		t.Fatalf("restored = %v, want only the failed message", msgs)
+	}
+}
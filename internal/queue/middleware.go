@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// Middleware 消费端中间件，包装消息处理函数，与 gin 的中间件类似
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// BatchMiddleware 批量消费的中间件，包装批量处理函数
+type BatchMiddleware func(next BatchHandlerFunc) BatchHandlerFunc
+
+var (
+	middlewares      []Middleware
+	batchMiddlewares []BatchMiddleware
+	middlewaresMu    sync.RWMutex
+)
+
+// Use 注册消费端中间件，先注册的在外层，对之后创建的消费者生效
+func Use(mw ...Middleware) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	middlewares = append(middlewares, mw...)
+}
+
+// Chain 用已注册的中间件包装 h
+func Chain(h HandlerFunc) HandlerFunc {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// UseBatch 注册批量消费的中间件，先注册的在外层，对之后创建的消费者生效
+func UseBatch(mw ...BatchMiddleware) {
+	middlewaresMu.Lock()
+	defer middlewaresMu.Unlock()
+	batchMiddlewares = append(batchMiddlewares, mw...)
+}
+
+// ChainBatch 用已注册的批量中间件包装 h
+func ChainBatch(h BatchHandlerFunc) BatchHandlerFunc {
+	middlewaresMu.RLock()
+	defer middlewaresMu.RUnlock()
+	for i := len(batchMiddlewares) - 1; i >= 0; i-- {
+		h = batchMiddlewares[i](h)
+	}
+	return h
+}
+
+type queueNameKey struct{}
+
+// WithQueueName 在 ctx 中记录消息所在的队列，供中间件使用
+func WithQueueName(ctx context.Context, queueName string) context.Context {
+	return context.WithValue(ctx, queueNameKey{}, queueName)
+}
+
+// QueueName 消息所在的队列，没有记录时返回空字符串
+func QueueName(ctx context.Context) string {
+	name, _ := ctx.Value(queueNameKey{}).(string)
+	return name
+}
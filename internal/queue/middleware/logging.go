@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/sirupsen/logrus"
+)
+
+// Logging 记录每条消息的处理结果和耗时
+func Logging() queue.Middleware {
+	return func(next queue.HandlerFunc) queue.HandlerFunc {
+		return func(ctx context.Context, message *queue.Message) error {
+			start := time.Now()
+			err := next(ctx, message)
+			entry := log.Logger.WithFields(logrus.Fields{
+				"queue":     queue.QueueName(ctx),
+				"msg_id":    message.Id,
+				"msg_type":  message.MsgType,
+				"re_in":     message.ReInCount,
+				"priority":  message.Priority,
+				"duration":  time.Since(start).String(),
+				"dedup_key": message.DedupKey(),
+				"trace_id":  message.TraceId,
+				"producer":  message.Producer,
+				"tenant":    message.Tenant,
+			})
+			if err != nil {
+				entry.WithError(err).Error("handle message failed")
+				return err
+			}
+			entry.Info("handle message success")
+			return nil
+		}
+	}
+}
+
+// LoggingBatch 记录每批消息的处理结果和耗时
+func LoggingBatch() queue.BatchMiddleware {
+	return func(next queue.BatchHandlerFunc) queue.BatchHandlerFunc {
+		return func(ctx context.Context, messages []*queue.Message) error {
+			start := time.Now()
+			err := next(ctx, messages)
+			var failed int
+			for _, message := range messages {
+				if queue.FailedIn(err, message) != nil {
+					failed++
+				}
+			}
+			entry := log.Logger.WithFields(logrus.Fields{
+				"queue":    queue.QueueName(ctx),
+				"msg_type": messages[0].MsgType,
+				"count":    len(messages),
+				"failed":   failed,
+				"duration": time.Since(start).String(),
+				"trace_id": messages[0].TraceId,
+			})
+			if err != nil {
+				entry.WithError(err).Error("handle batch failed")
+				return err
+			}
+			entry.Info("handle batch success")
+			return nil
+		}
+	}
+}
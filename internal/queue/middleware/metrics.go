@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// Stat 按消息类型统计的处理情况
+type Stat struct {
+	Processed int64         // 处理次数
+	Failed    int64         // 失败次数
+	Total     time.Duration // 总耗时
+	Max       time.Duration // 最大耗时
+}
+
+// Avg 平均耗时
+func (s Stat) Avg() time.Duration {
+	if s.Processed == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Processed)
+}
+
+var (
+	stats   = make(map[string]*Stat)
+	statsMu sync.Mutex
+)
+
+// Metrics 统计每种消息的处理次数、失败次数和耗时，通过 Stats 查看，消费进程定时上报到 /admin/queue/status (见 monitor)
+func Metrics() queue.Middleware {
+	return func(next queue.HandlerFunc) queue.HandlerFunc {
+		return func(ctx context.Context, message *queue.Message) error {
+			start := time.Now()
+			err := next(ctx, message)
+			observe(message.MsgType, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// MetricsBatch 按条统计批量处理的消息，每条消息的耗时为整批耗时的均摊
+func MetricsBatch() queue.BatchMiddleware {
+	return func(next queue.BatchHandlerFunc) queue.BatchHandlerFunc {
+		return func(ctx context.Context, messages []*queue.Message) error {
+			start := time.Now()
+			err := next(ctx, messages)
+			d := time.Since(start) / time.Duration(len(messages))
+			for _, message := range messages {
+				observe(message.MsgType, d, queue.FailedIn(err, message))
+			}
+			return err
+		}
+	}
+}
+
+func observe(msgType string, d time.Duration, err error) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	s, ok := stats[msgType]
+	if !ok {
+		s = &Stat{}
+		stats[msgType] = s
+	}
+	s.Processed++
+	if err != nil {
+		s.Failed++
+	}
+	s.Total += d
+	s.Max = max(s.Max, d)
+}
+
+// Stats 当前进程内各消息类型的统计
+func Stats() map[string]Stat {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	snapshot := make(map[string]Stat, len(stats))
+	for t, s := range stats {
+		snapshot[t] = *s
+	}
+	return snapshot
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// Recover 捕获处理函数中的 panic 并转换为错误，交给重试策略处理，避免整个消费进程崩溃
+func Recover() queue.Middleware {
+	return func(next queue.HandlerFunc) queue.HandlerFunc {
+		return func(ctx context.Context, message *queue.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Logger.Errorf("Handle message %s of type %s panic: %v\n%s", message.Id, message.MsgType, r, debug.Stack())
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return next(ctx, message)
+		}
+	}
+}
+
+// RecoverBatch 捕获批量处理函数中的 panic，整批视为失败
+func RecoverBatch() queue.BatchMiddleware {
+	return func(next queue.BatchHandlerFunc) queue.BatchHandlerFunc {
+		return func(ctx context.Context, messages []*queue.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Logger.Errorf("Handle %d messages of type %s panic: %v\n%s", len(messages), messages[0].MsgType, r, debug.Stack())
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return next(ctx, messages)
+		}
+	}
+}
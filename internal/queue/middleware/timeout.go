@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// Timeout 为每条消息的处理设置超时，处理函数需要通过 ctx 感知超时
+// d 小于等于 0 时不设置超时
+func Timeout(d time.Duration) queue.Middleware {
+	return func(next queue.HandlerFunc) queue.HandlerFunc {
+		if d <= 0 {
+			return next
+		}
+		return func(ctx context.Context, message *queue.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, message)
+		}
+	}
+}
+
+// TimeoutBatch 为每批消息的处理设置超时，d 小于等于 0 时不设置超时
+func TimeoutBatch(d time.Duration) queue.BatchMiddleware {
+	return func(next queue.BatchHandlerFunc) queue.BatchHandlerFunc {
+		if d <= 0 {
+			return next
+		}
+		return func(ctx context.Context, messages []*queue.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, messages)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package monitor 消费进程定时把运行状态写入 Redis，gint serve 的管理接口读取所有消费进程的状态
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/middleware"
+)
+
+const (
+	statusKey      = "queue:status" // hash，field 为消费进程标识
+	ReportInterval = 10 * time.Second
+	// staleAfter 超过这个时间没有更新的进程视为已经退出
+	staleAfter = 3 * ReportInterval
+)
+
+// Status 一个消费进程的运行状态
+type Status struct {
+	Node      string                    `json:"node"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+	Queues    map[string]queue.PoolStat `json:"queues"`   // 各队列正在处理中的消息数和 worker 数量
+	Messages  map[string]MessageStat    `json:"messages"` // 按消息类型统计的处理情况，需要注册 middleware.Metrics
+}
+
+// MessageStat 进程启动以来某种消息的处理情况，耗时单位为毫秒
+type MessageStat struct {
+	Processed int64   `json:"processed"`
+	Failed    int64   `json:"failed"`
+	AvgMs     float64 `json:"avg_ms"`
+	MaxMs     float64 `json:"max_ms"`
+}
+
+// Collect 当前进程的运行状态
+func Collect(node string) Status {
+	stats := middleware.Stats()
+	messages := make(map[string]MessageStat, len(stats))
+	for msgType, s := range stats {
+		messages[msgType] = MessageStat{
+			Processed: s.Processed,
+			Failed:    s.Failed,
+			AvgMs:     float64(s.Avg()) / float64(time.Millisecond),
+			MaxMs:     float64(s.Max) / float64(time.Millisecond),
+		}
+	}
+	return Status{
+		Node:      node,
+		UpdatedAt: time.Now(),
+		Queues:    queue.PoolStats(),
+		Messages:  messages,
+	}
+}
+
+// Node 当前进程的标识，consumerId 为空时使用 hostname-pid
+func Node(consumerId string) string {
+	if consumerId != "" {
+		return consumerId
+	}
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// Report 每隔 ReportInterval 写入一次当前进程的状态，ctx 取消时删除
+func Report(ctx context.Context, client redis.Cmdable, node string) {
+	ticker := time.NewTicker(ReportInterval)
+	defer ticker.Stop()
+	for {
+		if err := write(ctx, client, Collect(node)); err != nil && ctx.Err() == nil {
+			log.Logger.Errorf("Report queue status of %s failed: %v", node, err)
+		}
+		select {
+		case <-ctx.Done():
+			client.HDel(context.Background(), statusKey, node)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func write(ctx context.Context, client redis.Cmdable, status Status) error {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return client.HSet(ctx, statusKey, status.Node, b).Err()
+}
+
+// List 所有仍在运行的消费进程的状态，按标识排序，顺便清理已经退出的进程
+func List(ctx context.Context, client redis.Cmdable) ([]Status, error) {
+	all, err := client.HGetAll(ctx, statusKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Status, 0, len(all))
+	for node, raw := range all {
+		var status Status
+		if json.Unmarshal([]byte(raw), &status) != nil || time.Since(status.UpdatedAt) > staleAfter {
+			client.HDel(ctx, statusKey, node)
+			continue
+		}
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Node < list[j].Node })
+	return list, nil
+}
@@ -0,0 +1,127 @@
+package mysql_queue
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/database/model"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	dedupProcessing = "processing"
+	dedupDone       = "done"
+
+	dedupKeySize    = 191 // 与 QueueDedup.DedupKey 的字段长度一致
+	dedupGCInterval = time.Minute
+	dedupGCBatch    = 1000
+)
+
+// DedupStore 基于 QueueDedup 表的去重存储，多个消费进程共享
+type DedupStore struct {
+	db *gorm.DB
+
+	mu     sync.Mutex
+	lastGC time.Time
+}
+
+var _ queue.DedupStore = (*DedupStore)(nil)
+
+// NewDedupStore 创建 MySQL 去重存储，表结构由 model 自动迁移
+func NewDedupStore(db *gorm.DB) *DedupStore {
+	return &DedupStore{db: db, lastGC: time.Now()}
+}
+
+// dedupKey 超过字段长度的 key 使用 sha256 摘要
+func dedupKey(key string) string {
+	if len(key) <= dedupKeySize {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Claim 插入占用记录，key 已存在且占用已经过期时抢占，否则按记录的状态区分处理中和已经处理成功
+// 每一步都是单条语句，不需要加锁读
+func (s *DedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (queue.ClaimResult, error) {
+	s.gc(ctx)
+	key = dedupKey(key)
+	now := time.Now()
+	db := s.db.WithContext(ctx)
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.QueueDedup{
+		DedupKey:  key,
+		State:     dedupProcessing,
+		ExpiresAt: now.Add(ttl),
+	})
+	if res.Error != nil {
+		return queue.ClaimBusy, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return queue.ClaimOK, nil
+	}
+	res = db.Model(&model.QueueDedup{}).
+		Where("dedup_key = ? AND expires_at <= ?", key, now).
+		Updates(map[string]interface{}{"state": dedupProcessing, "expires_at": now.Add(ttl)})
+	if res.Error != nil {
+		return queue.ClaimBusy, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return queue.ClaimOK, nil
+	}
+	var row model.QueueDedup
+	err := db.Where("dedup_key = ?", key).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 刚好被释放，交给下一次投递
+		return queue.ClaimBusy, nil
+	}
+	if err != nil {
+		return queue.ClaimBusy, err
+	}
+	if row.State == dedupDone {
+		return queue.ClaimDone, nil
+	}
+	return queue.ClaimBusy, nil
+}
+
+// Done 标记 key 对应的消息处理成功，ttl 后过期
+func (s *DedupStore) Done(ctx context.Context, key string, ttl time.Duration) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"state", "expires_at"}),
+	}).Create(&model.QueueDedup{
+		DedupKey:  dedupKey(key),
+		State:     dedupDone,
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+}
+
+// Release 释放处理中的 key，已经处理成功的 key 不受影响
+func (s *DedupStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).
+		Where("dedup_key = ? AND state = ?", dedupKey(key), dedupProcessing).
+		Delete(&model.QueueDedup{}).Error
+}
+
+// gc 每分钟最多清理一批过期的记录
+func (s *DedupStore) gc(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastGC) <= dedupGCInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastGC = time.Now()
+	s.mu.Unlock()
+	err := s.db.WithContext(ctx).
+		Where("expires_at < ?", time.Now()).
+		Limit(dedupGCBatch).
+		Delete(&model.QueueDedup{}).Error
+	if err != nil {
+		log.Logger.Errorf("Clean up expired dedup keys failed: %v", err)
+	}
+}
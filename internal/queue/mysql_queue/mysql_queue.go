@@ -0,0 +1,327 @@
+package mysql_queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/database/model"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultPollInterval      = time.Second
+)
+
+var (
+	_ queue.Inspector = (*MysqlDriver)(nil)
+	_ queue.Requeuer  = (*MysqlDriver)(nil)
+)
+
+// MysqlDriver 使用 MySQL 表实现的队列驱动，适合没有 Redis 的部署
+// 消费者通过 SELECT ... FOR UPDATE SKIP LOCKED 领取消息，领取后 available_at 延长为可见性超时的截止时间，
+// 处理完成后移动到归档表；进程崩溃或处理超时的消息在截止时间之后会被重新领取 (at-least-once)
+type MysqlDriver struct {
+	db           *gorm.DB
+	consumerId   string
+	visibility   time.Duration
+	pollInterval time.Duration
+
+	mu     sync.Mutex
+	scheds map[string]*queue.LaneScheduler
+
+	unfinished queue.Unfinished[*model.QueueMessage] // 已经领取但 handler 被中断的消息
+}
+
+// Option MysqlDriver 的可选配置
+type Option func(d *MysqlDriver)
+
+// WithConsumerId 消费者标识，记录在领取的消息上便于排查
+func WithConsumerId(consumerId string) Option {
+	return func(d *MysqlDriver) {
+		d.consumerId = consumerId
+	}
+}
+
+// WithVisibilityTimeout 消息领取后多久没有确认就可以被重新领取
+func WithVisibilityTimeout(visibility time.Duration) Option {
+	return func(d *MysqlDriver) {
+		d.visibility = visibility
+	}
+}
+
+// WithPollInterval 队列为空时的轮询间隔
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *MysqlDriver) {
+		d.pollInterval = interval
+	}
+}
+
+// ConfigOptions 根据 config.Conf.Queue 生成驱动配置
+func ConfigOptions() []Option {
+	conf := config.Conf.Queue
+	return []Option{
+		WithConsumerId(conf.ConsumerId),
+		WithVisibilityTimeout(time.Duration(conf.VisibilityTimeout) * time.Second),
+		WithPollInterval(time.Duration(conf.PollInterval) * time.Millisecond),
+	}
+}
+
+// NewMysqlDriver 创建 MySQL 队列驱动，表结构由 model 自动迁移
+func NewMysqlDriver(db *gorm.DB, opts ...Option) *MysqlDriver {
+	d := &MysqlDriver{
+		db:     db,
+		scheds: make(map[string]*queue.LaneScheduler),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.consumerId == "" {
+		hostname, _ := os.Hostname()
+		d.consumerId = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if d.visibility <= 0 {
+		d.visibility = defaultVisibilityTimeout
+	}
+	if d.pollInterval <= 0 {
+		d.pollInterval = defaultPollInterval
+	}
+	return d
+}
+
+// scheduler 同一队列的多个消费者共享通道调度器
+func (d *MysqlDriver) scheduler(queueName string) *queue.LaneScheduler {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.scheds[queueName]
+	if !ok {
+		s = queue.NewLaneScheduler()
+		d.scheds[queueName] = s
+	}
+	return s
+}
+
+// Publish 写入一条立即可以领取的消息
+func (d *MysqlDriver) Publish(ctx context.Context, queueName string, message *queue.Message) error {
+	return d.insert(ctx, queueName, message, time.Now())
+}
+
+// PublishDelayed 延迟投递，消息在 delay 之后才能被领取
+func (d *MysqlDriver) PublishDelayed(ctx context.Context, queueName string, message *queue.Message, delay time.Duration) error {
+	return d.insert(ctx, queueName, message, time.Now().Add(delay))
+}
+
+// PublishAt 在指定时间投递
+func (d *MysqlDriver) PublishAt(ctx context.Context, queueName string, message *queue.Message, at time.Time) error {
+	return d.insert(ctx, queueName, message, at)
+}
+
+func (d *MysqlDriver) insert(ctx context.Context, queueName string, message *queue.Message, at time.Time) error {
+	message.Stamp(ctx)
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return d.db.WithContext(ctx).Create(&model.QueueMessage{
+		QueueName:   queueName,
+		MsgId:       message.Id,
+		Lane:        queue.Lane(message.Priority),
+		Payload:     string(payload),
+		AvailableAt: at,
+	}).Error
+}
+
+// Consume 轮询领取消息，处理成功后归档，失败的消息在可见性超时之后重新领取
+func (d *MysqlDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	pool := queue.Pool(queueName)
+	sched := d.scheduler(queueName)
+	for {
+		// 没有空闲 worker 时不再领取消息
+		if err := pool.Acquire(ctx); err != nil {
+			return nil
+		}
+		row, err := d.claim(ctx, queueName, sched.Order())
+		if err != nil || row == nil {
+			pool.Release()
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				log.Logger.Errorf("Claim message from %s failed: %v", queueName, err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(d.pollInterval):
+			}
+			continue
+		}
+		message := &queue.Message{}
+		if err = json.Unmarshal([]byte(row.Payload), message); err != nil {
+			pool.Release()
+			// 无法解析的消息重试也没有意义，原始内容放入死信队列之后才归档，失败时保留，可见性超时后重新领取
+			log.Logger.Errorf("Failed to unmarshal message payload %s from %s, move to %s: %v", row.MsgId, queueName, queue.DeadQueueName, err)
+			if err = d.Publish(context.WithoutCancel(ctx), queue.DeadQueueName, queue.Undecodable(queueName, row.Payload, err)); err != nil {
+				log.Logger.Errorf("Move undecodable message %s of %s to %s failed: %v", row.MsgId, queueName, queue.DeadQueueName, err)
+				continue
+			}
+			d.archive(context.WithoutCancel(ctx), row)
+			continue
+		}
+		pool.Go(func() {
+			err := handler(ctx, message)
+			if d.unfinished.Finish(row, err) {
+				return
+			}
+			if err != nil {
+				// 消费者只有在重新发布也失败时才返回错误，保留这条消息，可见性超时后重新领取
+				log.Logger.Errorf("Handle message from %s failed, will be reclaimed after %s: %v", queueName, d.visibility, err)
+				return
+			}
+			d.archive(context.WithoutCancel(ctx), row)
+		})
+	}
+}
+
+// RequeueUnfinished 释放 handler 被中断的消息，不必等到可见性超时就可以被重新领取
+// 仍在运行的 handler 领取的消息在可见性超时之后重新领取
+func (d *MysqlDriver) RequeueUnfinished(ctx context.Context) (int, error) {
+	rows := d.unfinished.Take()
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	tokens := make([]string, len(rows))
+	for i, row := range rows {
+		tokens[i] = row.LockToken
+	}
+	res := d.db.WithContext(ctx).Model(&model.QueueMessage{}).
+		Where("lock_token IN ?", tokens).
+		Updates(map[string]interface{}{
+			"available_at": time.Now(),
+			"lock_token":   "",
+			"locked_by":    "",
+		})
+	return int(res.RowsAffected), res.Error
+}
+
+// claim 按通道顺序 (防饿死时低优先级在前) 领取一条到期的消息，同一通道内按 available_at, id 取最早到期的
+// 每个通道单独查询，在 idx_queue_due 上只扫描已经到期的消息
+func (d *MysqlDriver) claim(ctx context.Context, queueName string, order []int) (*model.QueueMessage, error) {
+	var row model.QueueMessage
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := gorm.ErrRecordNotFound
+		for _, lane := range order {
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("queue_name = ? AND lane = ? AND available_at <= ?", queueName, lane, now).
+				Order("available_at, id").
+				Limit(1).
+				Take(&row).Error
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+		row.AvailableAt = now.Add(d.visibility)
+		row.Deliveries++
+		row.LockToken = queue.NewId()
+		row.LockedBy = d.consumerId
+		return tx.Model(&row).Updates(map[string]interface{}{
+			"available_at": row.AvailableAt,
+			"deliveries":   row.Deliveries,
+			"lock_token":   row.LockToken,
+			"locked_by":    row.LockedBy,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// archive 把处理完成的消息移动到归档表
+// 消息已经超时被其他消费者重新领取时 (lock_token 不一致) 不做处理
+func (d *MysqlDriver) archive(ctx context.Context, row *model.QueueMessage) {
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND lock_token = ?", row.Id, row.LockToken).Delete(&model.QueueMessage{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return tx.Create(&model.QueueMessageArchive{
+			Id:          row.Id,
+			QueueName:   row.QueueName,
+			MsgId:       row.MsgId,
+			Payload:     row.Payload,
+			Deliveries:  row.Deliveries,
+			CreatedAt:   row.CreatedAt,
+			CompletedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		log.Logger.Errorf("Archive message %s failed: %v", row.MsgId, err)
+	}
+}
+
+// Len 队列中的消息数量 (包含未到期和处理中的消息)
+func (d *MysqlDriver) Len(ctx context.Context, queueName string) (int64, error) {
+	var n int64
+	err := d.db.WithContext(ctx).Model(&model.QueueMessage{}).Where("queue_name = ?", queueName).Count(&n).Error
+	return n, err
+}
+
+// List 分页查看队列中的消息，按优先级和入队顺序排列
+func (d *MysqlDriver) List(ctx context.Context, queueName string, offset, limit int64) ([]*queue.Message, error) {
+	var rows []model.QueueMessage
+	err := d.db.WithContext(ctx).
+		Where("queue_name = ?", queueName).
+		Order("lane, id").
+		Offset(int(offset)).
+		Limit(int(limit)).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*queue.Message, 0, len(rows))
+	for _, row := range rows {
+		// 无法解析的消息以 msg_id 作为占位消息的 Id，Remove 可以删除
+		msgs = append(msgs, queue.DecodeStored(row.Payload, row.MsgId))
+	}
+	return msgs, nil
+}
+
+// Remove 删除指定 Id 的消息
+func (d *MysqlDriver) Remove(ctx context.Context, queueName, id string) error {
+	res := d.db.WithContext(ctx).Where("queue_name = ? AND msg_id = ?", queueName, id).Delete(&model.QueueMessage{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return queue.ErrMessageNotFound
+	}
+	return nil
+}
+
+// Purge 清空队列
+func (d *MysqlDriver) Purge(ctx context.Context, queueName string) (int64, error) {
+	res := d.db.WithContext(ctx).Where("queue_name = ?", queueName).Delete(&model.QueueMessage{})
+	return res.RowsAffected, res.Error
+}
+
+// Close 数据库连接由 mysql 包统一管理，这里不做处理
+func (d *MysqlDriver) Close() error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package nats_queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/nats-io/nats.go"
+)
+
+const broadcastSubject = "gint.broadcast."
+
+var _ queue.Broadcaster = (*Broadcaster)(nil)
+
+// Broadcaster 使用 NATS core pub/sub 实现的广播，连接同一个 NATS 服务的所有进程都能收到消息
+// 广播不经过 JetStream，不持久化
+type Broadcaster struct {
+	nc    *nats.Conn
+	local bool // 连接的是内嵌的 nats-server
+}
+
+var _ queue.Local = (*Broadcaster)(nil)
+
+// NewBroadcaster 使用已有的 NATS 连接创建广播，不负责关闭连接
+func NewBroadcaster(nc *nats.Conn) *Broadcaster {
+	return &Broadcaster{nc: nc}
+}
+
+// Broadcaster 创建与驱动共用连接的广播
+func (d *JetStreamDriver) Broadcaster() *Broadcaster {
+	return &Broadcaster{nc: d.nc, local: d.Embedded()}
+}
+
+// Local 连接内嵌的 nats-server 时只有同一进程内的订阅者能收到消息
+func (b *Broadcaster) Local() bool {
+	return b.local
+}
+
+// Broadcast 发布到 topic 对应的 subject
+func (b *Broadcaster) Broadcast(ctx context.Context, topic string, message *queue.Message) error {
+	message.Stamp(ctx)
+	payloadJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return b.nc.Publish(broadcastSubject+token(topic), payloadJSON)
+}
+
+// Subscribe 订阅 topic 对应的 subject，直到 ctx 取消或连接关闭
+func (b *Broadcaster) Subscribe(ctx context.Context, topic string, handler queue.HandlerFunc) error {
+	sub, err := b.nc.SubscribeSync(broadcastSubject + token(topic))
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	// 等待服务端确认订阅，之后发布的消息都能收到
+	if err = b.nc.FlushWithContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, nats.ErrSlowConsumer) {
+				log.Logger.Warnf("Subscriber of topic %s is too slow, some broadcasts are dropped", topic)
+				continue
+			}
+			return err
+		}
+		message := &queue.Message{}
+		if err = json.Unmarshal(msg.Data, message); err != nil {
+			log.Logger.Errorf("Failed to unmarshal broadcast of topic %s: %v", topic, err)
+			continue
+		}
+		_ = handler(ctx, message)
+	}
+}
@@ -0,0 +1,110 @@
+package nats_queue
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	dedupBucket = "gint_dedup"
+
+	dedupProcessing = "processing:" // 后面跟占用过期的毫秒时间戳
+	dedupDone       = "done"
+)
+
+// DedupStore 基于 JetStream KV 的去重存储，多个消费进程共享
+// KV 不支持单个 key 的过期时间：处理中的 key 在值中记录过期时间，已经处理成功的 key 随 bucket 的 TTL 过期
+type DedupStore struct {
+	kv jetstream.KeyValue
+}
+
+var _ queue.DedupStore = (*DedupStore)(nil)
+
+// DedupStore 创建或打开去重使用的 KV bucket，ttl 为处理成功的记录的保留时间
+func (d *JetStreamDriver) DedupStore(ctx context.Context, ttl time.Duration) (*DedupStore, error) {
+	kv, err := d.js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:  dedupBucket,
+		TTL:     ttl,
+		Storage: jetstream.FileStorage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DedupStore{kv: kv}, nil
+}
+
+// dedupKey KV 的 key 只允许字母、数字和 -/_=.，按 URL 安全的 base64 编码
+func dedupKey(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// Claim 使用 Create 占用 key，key 已存在时按它的值区分处理中和已经处理成功，占用已经过期时按 revision 抢占
+func (s *DedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (queue.ClaimResult, error) {
+	key = dedupKey(key)
+	claimed := []byte(dedupProcessing + strconv.FormatInt(time.Now().Add(ttl).UnixMilli(), 10))
+	_, err := s.kv.Create(ctx, key, claimed)
+	if err == nil {
+		return queue.ClaimOK, nil
+	}
+	if !errors.Is(err, jetstream.ErrKeyExists) {
+		return queue.ClaimBusy, err
+	}
+	entry, err := s.kv.Get(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		// 刚好被释放，交给下一次投递
+		return queue.ClaimBusy, nil
+	}
+	if err != nil {
+		return queue.ClaimBusy, err
+	}
+	value := string(entry.Value())
+	if value == dedupDone {
+		return queue.ClaimDone, nil
+	}
+	expires, _ := strconv.ParseInt(strings.TrimPrefix(value, dedupProcessing), 10, 64)
+	if time.Now().UnixMilli() < expires {
+		return queue.ClaimBusy, nil
+	}
+	// 占用已经过期，revision 不一致说明被其他消费者抢先占用
+	if _, err = s.kv.Update(ctx, key, claimed, entry.Revision()); err != nil {
+		if errors.Is(err, jetstream.ErrKeyExists) {
+			return queue.ClaimBusy, nil
+		}
+		return queue.ClaimBusy, err
+	}
+	return queue.ClaimOK, nil
+}
+
+// Done 标记 key 对应的消息处理成功，保留时间由 bucket 的 TTL 决定
+func (s *DedupStore) Done(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := s.kv.PutString(ctx, dedupKey(key), dedupDone)
+	return err
+}
+
+// Release 释放处理中的 key，已经处理成功的 key 不受影响
+func (s *DedupStore) Release(ctx context.Context, key string) error {
+	key = dedupKey(key)
+	entry, err := s.kv.Get(ctx, key)
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if string(entry.Value()) == dedupDone {
+		return nil
+	}
+	err = s.kv.Delete(ctx, key, jetstream.LastRevision(entry.Revision()))
+	if errors.Is(err, jetstream.ErrKeyExists) {
+		// 已经被其他消费者重新占用或者标记成功
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,391 @@
+package nats_queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	defaultDurable    = "gint"
+	defaultAckWait    = 30 * time.Second
+	defaultStreamName = "QUEUE"
+	defaultSubject    = "queue"
+	fetchTimeout      = 5 * time.Second // 单次拉取请求的超时时间
+	headerDeliverAt   = "Gint-Deliver-At"
+)
+
+var (
+	_ queue.Requeuer = (*JetStreamDriver)(nil)
+	_ queue.Local    = (*JetStreamDriver)(nil)
+)
+
+// JetStreamDriver 使用 NATS JetStream 实现的队列驱动
+// 每个队列对应一个 WorkQueue 策略的 stream 和一个 durable pull consumer，多个 gint consumer 进程共享同一个 durable，
+// 消息处理成功后 ack，处理失败或进程崩溃的消息在 ackWait 之后由服务端重新投递
+// 延迟消息带上投递时间的 header 直接写入队列，未到期时 NakWithDelay 推迟到投递时间
+// JetStream 驱动不区分 Message.Priority，所有消息按写入顺序消费
+type JetStreamDriver struct {
+	nc      *nats.Conn
+	js      jetstream.JetStream
+	durable string
+	ackWait time.Duration
+	srv     *server.Server // 内嵌的 nats-server，Close 时一起关闭
+
+	mu      sync.Mutex
+	streams map[string]bool // 已经创建过的 stream
+
+	unfinished queue.Unfinished[jetstream.Msg] // 已经拉取但 handler 被中断的消息
+}
+
+// Option JetStreamDriver 的可选配置
+type Option func(d *JetStreamDriver)
+
+// WithDurable 设置 durable consumer 名称，同名的消费者共同消费一个队列
+func WithDurable(durable string) Option {
+	return func(d *JetStreamDriver) {
+		d.durable = durable
+	}
+}
+
+// WithAckWait 消息投递后多久没有 ack 就重新投递
+func WithAckWait(ackWait time.Duration) Option {
+	return func(d *JetStreamDriver) {
+		d.ackWait = ackWait
+	}
+}
+
+// withServer Close 时一起关闭内嵌的 nats-server
+func withServer(srv *server.Server) Option {
+	return func(d *JetStreamDriver) {
+		d.srv = srv
+	}
+}
+
+// ConfigOptions 根据 config.Conf.Queue 生成驱动配置
+func ConfigOptions() []Option {
+	conf := config.Conf.Queue
+	return []Option{
+		WithDurable(conf.Group),
+		WithAckWait(time.Duration(conf.VisibilityTimeout) * time.Second),
+	}
+}
+
+// NewJetStreamDriver 使用已有的 NATS 连接创建 JetStream 驱动，Close 时会关闭连接
+func NewJetStreamDriver(nc *nats.Conn, opts ...Option) (*JetStreamDriver, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	d := &JetStreamDriver{nc: nc, js: js, streams: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.durable == "" {
+		d.durable = defaultDurable
+	}
+	if d.ackWait <= 0 {
+		d.ackWait = defaultAckWait
+	}
+	return d, nil
+}
+
+// Conn 驱动使用的 NATS 连接，广播等功能可以共用
+func (d *JetStreamDriver) Conn() *nats.Conn {
+	return d.nc
+}
+
+// Embedded 是否连接的是进程内的 nats-server，其他进程无法连接
+func (d *JetStreamDriver) Embedded() bool {
+	return d.srv != nil
+}
+
+// Local 使用内嵌的 nats-server 时队列只在当前进程内可见
+func (d *JetStreamDriver) Local() bool {
+	return d.Embedded()
+}
+
+// Connect 连接 url 指定的 NATS 服务
+func Connect(url string, opts ...Option) (*JetStreamDriver, error) {
+	if url == "" {
+		return nil, errors.New("nats url is required, set queue.nats.embedded to use an in-process nats-server")
+	}
+	nc, err := nats.Connect(url, nats.Name("gint"))
+	if err != nil {
+		return nil, err
+	}
+	d, err := NewJetStreamDriver(nc, opts...)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// ConnectEmbedded 在进程内启动一个开启 JetStream 的 nats-server 并连接，数据保存在 storeDir
+// 内嵌的 nats-server 不监听端口，其他进程看不到其中的消息，只适合单进程的本地开发和测试
+func ConnectEmbedded(storeDir string, opts ...Option) (*JetStreamDriver, error) {
+	log.Logger.Warnf("Using embedded nats-server in %s, queue messages are only visible to this process", storeDir)
+	srv, err := RunEmbeddedServer(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	nc, err := nats.Connect("", nats.InProcessServer(srv))
+	if err != nil {
+		srv.Shutdown()
+		return nil, err
+	}
+	d, err := NewJetStreamDriver(nc, append(opts, withServer(srv))...)
+	if err != nil {
+		nc.Close()
+		srv.Shutdown()
+		return nil, err
+	}
+	return d, nil
+}
+
+// RunEmbeddedServer 在进程内启动一个只接受进程内连接的 nats-server
+func RunEmbeddedServer(storeDir string) (*server.Server, error) {
+	srv, err := server.NewServer(&server.Options{
+		ServerName: "gint-embedded",
+		DontListen: true,
+		JetStream:  true,
+		StoreDir:   storeDir,
+		NoSigs:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	srv.Start()
+	if !srv.ReadyForConnections(10 * time.Second) {
+		srv.Shutdown()
+		return nil, errors.New("embedded nats-server is not ready")
+	}
+	return srv, nil
+}
+
+// token 队列名中 stream 名称和 subject 不允许的字符替换为 _
+func token(queueName string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', '/', '\\', ' ', '\t', '\n':
+			return '_'
+		}
+		return r
+	}, queueName)
+}
+
+// streamName 队列对应的 stream
+func streamName(queueName string) string {
+	return defaultStreamName + "_" + token(queueName)
+}
+
+// subject 队列对应的 subject
+func subject(queueName string) string {
+	return defaultSubject + "." + token(queueName)
+}
+
+// ensureStream 创建队列对应的 stream，同一进程只需创建一次
+func (d *JetStreamDriver) ensureStream(ctx context.Context, queueName string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.streams[queueName] {
+		return nil
+	}
+	_, err := d.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      streamName(queueName),
+		Subjects:  []string{subject(queueName)},
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create stream for %s: %w", queueName, err)
+	}
+	d.streams[queueName] = true
+	return nil
+}
+
+// Publish 将消息写入队列对应的 stream，等待服务端确认
+func (d *JetStreamDriver) Publish(ctx context.Context, queueName string, message *queue.Message) error {
+	return d.publish(ctx, queueName, message, time.Time{})
+}
+
+// PublishDelayed 延迟投递
+func (d *JetStreamDriver) PublishDelayed(ctx context.Context, queueName string, message *queue.Message, delay time.Duration) error {
+	return d.PublishAt(ctx, queueName, message, time.Now().Add(delay))
+}
+
+// PublishAt 在指定时间投递
+func (d *JetStreamDriver) PublishAt(ctx context.Context, queueName string, message *queue.Message, at time.Time) error {
+	if !at.After(time.Now()) {
+		return d.Publish(ctx, queueName, message)
+	}
+	return d.publish(ctx, queueName, message, at)
+}
+
+func (d *JetStreamDriver) publish(ctx context.Context, queueName string, message *queue.Message, at time.Time) error {
+	if err := d.ensureStream(ctx, queueName); err != nil {
+		return err
+	}
+	message.Stamp(ctx)
+	payloadJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	msg := nats.NewMsg(subject(queueName))
+	msg.Data = payloadJSON
+	if !at.IsZero() {
+		msg.Header.Set(headerDeliverAt, strconv.FormatInt(at.UnixMilli(), 10))
+	}
+	_, err = d.js.PublishMsg(ctx, msg)
+	return err
+}
+
+// Consume 通过 durable pull consumer 拉取消息，处理成功后 ack
+func (d *JetStreamDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	if err := d.ensureStream(ctx, queueName); err != nil {
+		return err
+	}
+	cons, err := d.js.CreateOrUpdateConsumer(ctx, streamName(queueName), jetstream.ConsumerConfig{
+		Durable:    d.durable,
+		AckPolicy:  jetstream.AckExplicitPolicy,
+		AckWait:    d.ackWait,
+		MaxDeliver: -1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer for %s: %w", queueName, err)
+	}
+
+	pool := queue.Pool(queueName)
+	for {
+		// 没有空闲 worker 时不再拉取消息
+		if err = pool.Acquire(ctx); err != nil {
+			return nil
+		}
+		msg, err := d.next(ctx, cons)
+		if err != nil {
+			pool.Release()
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, nats.ErrTimeout) {
+				continue
+			}
+			if errors.Is(err, nats.ErrConnectionClosed) {
+				return err
+			}
+			log.Logger.Errorf("Fetch message from %s failed: %v", queueName, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if wait := deliverIn(msg); wait > 0 {
+			pool.Release()
+			// 延迟消息未到期，推迟到投递时间再重新投递
+			if err = msg.NakWithDelay(wait); err != nil {
+				log.Logger.Errorf("Delay message from %s failed: %v", queueName, err)
+			}
+			continue
+		}
+		payload := &queue.Message{}
+		if err = json.Unmarshal(msg.Data(), payload); err != nil {
+			pool.Release()
+			// 无法解析的消息重试也没有意义，原始内容放入死信队列之后才删除，失败时 Nak 等待重新投递
+			log.Logger.Errorf("Failed to unmarshal message payload from %s, move to %s: %v", queueName, queue.DeadQueueName, err)
+			if err = d.Publish(context.WithoutCancel(ctx), queue.DeadQueueName, queue.Undecodable(queueName, string(msg.Data()), err)); err != nil {
+				log.Logger.Errorf("Move undecodable message of %s to %s failed: %v", queueName, queue.DeadQueueName, err)
+				if err = msg.Nak(); err != nil {
+					log.Logger.Errorf("Nak undecodable message of %s failed: %v", queueName, err)
+				}
+				continue
+			}
+			if err = msg.Term(); err != nil {
+				log.Logger.Errorf("Term undecodable message of %s failed: %v", queueName, err)
+			}
+			continue
+		}
+		pool.Go(func() {
+			err := handler(ctx, payload)
+			if d.unfinished.Finish(msg, err) {
+				return
+			}
+			if err != nil {
+				log.Logger.Errorf("Handle message from %s failed: %v", queueName, err)
+				return
+			}
+			if err := msg.Ack(); err != nil {
+				log.Logger.Errorf("Ack message %s failed: %v", payload.Id, err)
+			}
+		})
+	}
+}
+
+// RequeueUnfinished Nak handler 被中断的消息，服务端立即重新投递，不必等到 ackWait
+// 仍在运行的 handler 拉取的消息在 ackWait 之后重新投递
+func (d *JetStreamDriver) RequeueUnfinished(ctx context.Context) (int, error) {
+	n := 0
+	var errs []error
+	for _, msg := range d.unfinished.Take() {
+		if err := msg.Nak(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// next 拉取一条消息，ctx 取消时立即返回
+// 已经发出的拉取请求仍可能收到消息，这条消息不会被 ack，ackWait 之后重新投递
+func (d *JetStreamDriver) next(ctx context.Context, cons jetstream.Consumer) (jetstream.Msg, error) {
+	batch, err := cons.Fetch(1, jetstream.FetchMaxWait(fetchTimeout))
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-batch.Messages():
+		if msg != nil {
+			return msg, nil
+		}
+		if err = batch.Error(); err != nil {
+			return nil, err
+		}
+		return nil, nats.ErrTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliverIn 延迟消息距离投递时间还有多久
+func deliverIn(msg jetstream.Msg) time.Duration {
+	v := msg.Headers().Get(headerDeliverAt)
+	if v == "" {
+		return 0
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Until(time.UnixMilli(ms))
+}
+
+// Close 关闭 NATS 连接，内嵌模式下同时关闭 nats-server
+func (d *JetStreamDriver) Close() error {
+	d.nc.Close()
+	if d.srv != nil {
+		d.srv.Shutdown()
+		d.srv.WaitForShutdown()
+	}
+	return nil
+}
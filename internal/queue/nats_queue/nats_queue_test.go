@@ -0,0 +1,227 @@
+package nats_queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/nats-io/nats.go"
+)
+
+func newTestDriver(t *testing.T, opts ...Option) *JetStreamDriver {
+	d, err := ConnectEmbedded(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+// consume 后台消费直到收到 n 条消息或超时
+func consume(t *testing.T, d *JetStreamDriver, queueName string, n int, handler func(m *queue.Message) error) []*queue.Message {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var (
+		mu   sync.Mutex
+		msgs []*queue.Message
+	)
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Consume(ctx, queueName, func(ctx context.Context, m *queue.Message) error {
+			if err := handler(m); err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			msgs = append(msgs, m)
+			if len(msgs) == n {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	<-ctx.Done()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(msgs) != n {
+		t.Fatalf("consumed %d messages, want %d", len(msgs), n)
+	}
+	return msgs
+}
+
+func TestPublishConsume(t *testing.T) {
+	d := newTestDriver(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := d.Publish(ctx, "test.publish", &queue.Message{MsgType: "test", Body: gin.H{"i": i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := consume(t, d, "test.publish", 3, func(m *queue.Message) error { return nil })
+	for i, m := range msgs {
+		if m.Id == "" || m.Body["i"] != float64(i) {
+			t.Fatalf("unexpected message %d: %+v", i, m)
+		}
+	}
+
+	// ack 之后 WorkQueue stream 中不再保留消息
+	time.Sleep(100 * time.Millisecond)
+	stream, err := d.js.Stream(ctx, streamName("test.publish"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 0 {
+		t.Fatalf("stream still has %d messages after ack", info.State.Msgs)
+	}
+}
+
+func TestRedelivery(t *testing.T) {
+	d := newTestDriver(t, WithAckWait(time.Second))
+	if err := d.Publish(context.Background(), "test.redelivery", &queue.Message{MsgType: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	var attempts int
+	consume(t, d, "test.redelivery", 1, func(m *queue.Message) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("fail first delivery")
+		}
+		return nil
+	})
+	if attempts != 2 {
+		t.Fatalf("handled %d times, want 2", attempts)
+	}
+}
+
+func TestUndecodableDeadLetter(t *testing.T) {
+	d := newTestDriver(t)
+	ctx := context.Background()
+	if err := d.ensureStream(ctx, "test.undecodable"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.js.Publish(ctx, subject("test.undecodable"), []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	// 无法解析的消息不会交给 handler
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go d.Consume(cctx, "test.undecodable", func(ctx context.Context, m *queue.Message) error {
+		t.Errorf("undecodable message handled: %+v", m)
+		return nil
+	})
+	msgs := consume(t, d, queue.DeadQueueName, 1, func(m *queue.Message) error { return nil })
+	if raw := msgs[0].Headers[queue.HeaderRawPayload]; raw != "not json" {
+		t.Fatalf("raw payload = %v, want %q", raw, "not json")
+	}
+	if origin := msgs[0].Headers[queue.HeaderOriginalQueue]; origin != "test.undecodable" {
+		t.Fatalf("original queue = %v, want test.undecodable", origin)
+	}
+}
+
+func TestPublishDelayed(t *testing.T) {
+	d := newTestDriver(t)
+	start := time.Now()
+	if err := d.PublishDelayed(context.Background(), "test.delayed", &queue.Message{MsgType: "test"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	consume(t, d, "test.delayed", 1, func(m *queue.Message) error { return nil })
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("delayed message consumed after %v", elapsed)
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	d := newTestDriver(t)
+	// 第二个连接模拟另一个进程
+	nc, err := nats.Connect("", nats.InProcessServer(d.srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan *queue.Message, 2)
+	conns := []*nats.Conn{d.Conn(), nc}
+	for _, c := range conns {
+		b := NewBroadcaster(c)
+		go func() {
+			_ = b.Subscribe(ctx, "test.broadcast", func(ctx context.Context, m *queue.Message) error {
+				received <- m
+				return nil
+			})
+		}()
+	}
+	// 等待两个订阅都发送到服务端
+	for _, c := range conns {
+		for c.NumSubscriptions() == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err = c.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = NewBroadcaster(d.Conn()).Broadcast(ctx, "test.broadcast", &queue.Message{MsgType: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-received:
+			if m.MsgType != "test" || m.Id == "" {
+				t.Fatalf("unexpected broadcast: %+v", m)
+			}
+		case <-ctx.Done():
+			t.Fatalf("received %d broadcasts, want 2", i)
+		}
+	}
+}
+
+func TestDedupStore(t *testing.T) {
+	d := newTestDriver(t)
+	ctx := context.Background()
+	s, err := d.DedupStore(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := func(key string, ttl time.Duration, want queue.ClaimResult) {
+		t.Helper()
+		got, err := s.Claim(ctx, key, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("Claim(%s) = %d, want %d", key, got, want)
+		}
+	}
+	claim("test:a", time.Minute, queue.ClaimOK)
+	claim("test:a", time.Minute, queue.ClaimBusy)
+	if err = s.Release(ctx, "test:a"); err != nil {
+		t.Fatal(err)
+	}
+	claim("test:a", time.Minute, queue.ClaimOK)
+	if err = s.Done(ctx, "test:a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Release(ctx, "test:a"); err != nil {
+		t.Fatal(err)
+	}
+	claim("test:a", time.Minute, queue.ClaimDone)
+
+	// 占用过期后可以被再次占用
+	claim("test:b", 50*time.Millisecond, queue.ClaimOK)
+	time.Sleep(100 * time.Millisecond)
+	claim("test:b", time.Minute, queue.ClaimOK)
+	claim("test:b", time.Minute, queue.ClaimBusy)
+}
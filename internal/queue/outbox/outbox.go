@@ -0,0 +1,269 @@
+// Package outbox 事务发件箱：业务数据和待发布消息在同一个数据库事务中写入，
+// 事务提交后由 Relay 把消息投递到队列，避免"提交失败却已发布"或"提交成功却丢消息"
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/database/model"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultInterval        = time.Second
+	defaultBatchSize       = 100
+	defaultMaxAttempts     = 10
+	defaultRetention       = 7 * 24 * time.Hour
+	defaultCleanupInterval = time.Hour
+	leaseTimeout           = 5 * time.Minute // 领取一批记录后投递的时限，过期后可以被其他 relay 重新领取
+	maxErrorLen            = 500
+)
+
+// Add 在事务 tx 中写入一条待发布的消息，和业务数据一起提交或回滚
+//
+//	err := db.Transaction(func(tx *gorm.DB) error {
+//		if err := repo.WithTrx(tx).Add(order); err != nil {
+//			return err
+//		}
+//		return outbox.Add(tx, "default", msg)
+//	})
+func Add(tx *gorm.DB, queueName string, message *queue.Message) error {
+	if tx == nil {
+		return errors.New("outbox: nil transaction")
+	}
+	// 写入前生成 Id，relay 重复投递时消费端可以据此去重
+	message.Stamp(tx.Statement.Context)
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return tx.Create(&model.QueueOutbox{
+		QueueName:   queueName,
+		MsgId:       message.Id,
+		Payload:     string(payload),
+		Status:      model.OutboxPending,
+		AvailableAt: time.Now(),
+	}).Error
+}
+
+// Relay 把发件箱中待投递的消息转发到队列驱动
+// 多个进程同时运行时在短事务中通过 SELECT ... FOR UPDATE SKIP LOCKED 领取记录，领取后 available_at 延长为租约的截止时间，
+// 投递在事务之外进行，每条记录单独更新结果；投递成功但状态更新失败或租约过期时消息会被再次投递 (at-least-once)
+type Relay struct {
+	db     *gorm.DB
+	driver queue.Driver
+
+	interval        time.Duration
+	batchSize       int
+	maxAttempts     int
+	retention       time.Duration
+	cleanupInterval time.Duration
+	backoff         queue.RetryPolicy
+}
+
+// Option Relay 的可选配置
+type Option func(r *Relay)
+
+// WithInterval 扫描发件箱的间隔
+func WithInterval(interval time.Duration) Option {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+// WithBatchSize 每次投递的最大条数
+func WithBatchSize(n int) Option {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// WithMaxAttempts 最大投递次数，超过后标记为失败
+func WithMaxAttempts(n int) Option {
+	return func(r *Relay) {
+		r.maxAttempts = n
+	}
+}
+
+// WithRetention 已投递记录的保留时间，过期后清理
+func WithRetention(retention time.Duration) Option {
+	return func(r *Relay) {
+		r.retention = retention
+	}
+}
+
+// ConfigOptions 根据 config.Conf.Queue.Outbox 生成 Relay 配置
+func ConfigOptions() []Option {
+	conf := config.Conf.Queue.Outbox
+	return []Option{
+		WithInterval(time.Duration(conf.Interval) * time.Second),
+		WithBatchSize(conf.BatchSize),
+		WithMaxAttempts(conf.MaxAttempts),
+		WithRetention(time.Duration(conf.Retention) * time.Second),
+	}
+}
+
+// NewRelay 创建发件箱 relay
+func NewRelay(db *gorm.DB, driver queue.Driver, opts ...Option) *Relay {
+	r := &Relay{
+		db:              db,
+		driver:          driver,
+		cleanupInterval: defaultCleanupInterval,
+		backoff: queue.RetryPolicy{
+			BaseDelay: time.Second,
+			MaxDelay:  5 * time.Minute,
+			Jitter:    0.2,
+		},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.interval <= 0 {
+		r.interval = defaultInterval
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultBatchSize
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = defaultMaxAttempts
+	}
+	if r.retention <= 0 {
+		r.retention = defaultRetention
+	}
+	return r
+}
+
+// Run 循环投递发件箱中的消息，直到 ctx 取消
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(r.cleanupInterval)
+	defer cleanup.Stop()
+	for {
+		// 一批投满说明还有积压，继续投递
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Logger.Errorf("Relay outbox failed: %v", err)
+			}
+			if err != nil || n < r.batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-cleanup.C:
+			if err := r.Cleanup(ctx); err != nil {
+				log.Logger.Errorf("Cleanup outbox failed: %v", err)
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce 投递一批到期的消息，返回本次处理的条数
+// ctx 取消时剩余的记录不再投递，租约过期后重新领取
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	rows, token, err := r.claim(ctx)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	for i := range rows {
+		if ctx.Err() != nil {
+			break
+		}
+		updates := r.publish(ctx, &rows[i])
+		// 不使用 ctx，避免退出时已投递的记录无法标记；租约过期后被其他 relay 重新领取的记录 (lock_token 不一致) 不更新
+		err = r.db.WithContext(context.WithoutCancel(ctx)).Model(&model.QueueOutbox{}).
+			Where("id = ? AND lock_token = ?", rows[i].Id, token).
+			Updates(updates).Error
+		if err != nil {
+			log.Logger.Errorf("Update outbox message %s failed: %v", rows[i].MsgId, err)
+		}
+	}
+	return len(rows), nil
+}
+
+// claim 在短事务中领取一批到期的记录，available_at 延长为租约的截止时间，返回本次领取的凭证
+func (r *Relay) claim(ctx context.Context) ([]model.QueueOutbox, string, error) {
+	var rows []model.QueueOutbox
+	token := queue.NewId()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND available_at <= ?", model.OutboxPending, now).
+			Order("id").
+			Limit(r.batchSize).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		ids := make([]uint64, len(rows))
+		for i, row := range rows {
+			ids[i] = row.Id
+		}
+		return tx.Model(&model.QueueOutbox{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"available_at": now.Add(leaseTimeout),
+			"lock_token":   token,
+		}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return rows, token, nil
+}
+
+// publish 投递一条记录，返回需要更新的字段
+func (r *Relay) publish(ctx context.Context, row *model.QueueOutbox) map[string]interface{} {
+	message := &queue.Message{}
+	err := json.Unmarshal([]byte(row.Payload), message)
+	if err == nil {
+		err = r.driver.Publish(ctx, row.QueueName, message)
+	}
+	if err == nil {
+		return map[string]interface{}{
+			"status":  model.OutboxSent,
+			"sent_at": time.Now(),
+		}
+	}
+
+	attempts := row.Attempts + 1
+	log.Logger.Errorf("Relay outbox message %s to %s failed (attempt %d): %v", row.MsgId, row.QueueName, attempts, err)
+	updates := map[string]interface{}{
+		"attempts":     attempts,
+		"last_error":   truncate(err.Error(), maxErrorLen),
+		"available_at": time.Now().Add(r.backoff.Backoff(attempts - 1)),
+	}
+	if attempts >= r.maxAttempts {
+		updates["status"] = model.OutboxFailed
+	}
+	return updates
+}
+
+// Cleanup 删除超过保留时间的已投递记录，投递失败的记录保留以便排查
+func (r *Relay) Cleanup(ctx context.Context) error {
+	return r.db.WithContext(ctx).
+		Where("status = ? AND sent_at < ?", model.OutboxSent, time.Now().Add(-r.retention)).
+		Delete(&model.QueueOutbox{}).Error
+}
+
+// truncate 按字符截断，避免写入半个 UTF-8 字符
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
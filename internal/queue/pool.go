@@ -0,0 +1,160 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+const DefaultConcurrency = 10
+
+// WorkerPool 限制单个队列同时处理的消息数
+// 驱动在拉取消息前先 Acquire，所有 worker 都忙碌时停止拉取，形成背压
+type WorkerPool struct {
+	sem chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	// idle 没有任务在执行时关闭，Wait 等待它关闭；不使用 WaitGroup，超时返回的 Wait 之后仍可以继续 Go
+	idle chan struct{}
+}
+
+// NewWorkerPool 创建一个大小为 size 的 worker pool
+func NewWorkerPool(size int) *WorkerPool {
+	if size <= 0 {
+		size = DefaultConcurrency
+	}
+	idle := make(chan struct{})
+	close(idle)
+	return &WorkerPool{sem: make(chan struct{}, size), idle: idle}
+}
+
+// Acquire 占用一个 worker，全部忙碌时阻塞，ctx 取消时返回 ctx.Err()
+func (p *WorkerPool) Acquire(ctx context.Context) error {
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release 释放 Acquire 占用的 worker (未执行任务时使用)
+func (p *WorkerPool) Release() {
+	<-p.sem
+}
+
+// Go 在已占用的 worker 上异步执行 fn，执行完成后自动释放
+func (p *WorkerPool) Go(fn func()) {
+	p.mu.Lock()
+	if p.inFlight == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.inFlight++
+	p.mu.Unlock()
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			p.inFlight--
+			if p.inFlight == 0 {
+				close(p.idle)
+			}
+			p.mu.Unlock()
+			p.Release()
+		}()
+		fn()
+	}()
+}
+
+// Wait 等待 Go 启动的任务全部完成，ctx 结束时返回 ctx.Err()，需要在停止拉取之后调用
+func (p *WorkerPool) Wait(ctx context.Context) error {
+	p.mu.Lock()
+	idle := p.idle
+	p.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InFlight 正在处理中的消息数
+func (p *WorkerPool) InFlight() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inFlight
+}
+
+// Size worker 数量
+func (p *WorkerPool) Size() int {
+	return cap(p.sem)
+}
+
+var (
+	pools              = make(map[string]*WorkerPool)
+	concurrency        = make(map[string]int)
+	defaultConcurrency = DefaultConcurrency
+	poolsMu            sync.Mutex
+)
+
+// SetDefaultConcurrency 设置未单独配置的队列的并发数，需要在开始消费前调用
+func SetDefaultConcurrency(n int) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	if n > 0 {
+		defaultConcurrency = n
+	}
+}
+
+// SetConcurrency 设置指定队列的并发数，需要在开始消费前调用
+func SetConcurrency(queueName string, n int) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	if n > 0 {
+		concurrency[queueName] = n
+	}
+}
+
+// Pool 获取队列对应的 worker pool，同一队列的多个消费者共享
+func Pool(queueName string) *WorkerPool {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	p, ok := pools[queueName]
+	if !ok {
+		size, ok := concurrency[queueName]
+		if !ok {
+			size = defaultConcurrency
+		}
+		p = NewWorkerPool(size)
+		pools[queueName] = p
+	}
+	return p
+}
+
+// InFlight 各队列正在处理中的消息数
+func InFlight() map[string]int {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	stats := make(map[string]int, len(pools))
+	for name, p := range pools {
+		stats[name] = p.InFlight()
+	}
+	return stats
+}
+
+// PoolStat 队列 worker pool 的使用情况
+type PoolStat struct {
+	InFlight int `json:"in_flight"` // 正在处理中的消息数
+	Size     int `json:"size"`      // worker 数量
+}
+
+// PoolStats 各队列 worker pool 的使用情况
+func PoolStats() map[string]PoolStat {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	stats := make(map[string]PoolStat, len(pools))
+	for name, p := range pools {
+		stats[name] = PoolStat{InFlight: p.InFlight(), Size: p.Size()}
+	}
+	return stats
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// 消息优先级，Message.Priority 大于 0 进入高优先级通道，小于 0 进入低优先级通道
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// LaneCount 优先级通道数量 (高、普通、低)
+const LaneCount = 3
+
+// DefaultStarvationEvery 默认每拉取多少次消息反转一次通道顺序
+const DefaultStarvationEvery = 10
+
+// Lane 优先级对应的通道下标，0 为最高优先级
+func Lane(priority int) int {
+	switch {
+	case priority > 0:
+		return 0
+	case priority < 0:
+		return 2
+	default:
+		return 1
+	}
+}
+
+var (
+	laneOrder       = []int{0, 1, 2}
+	reverseOrder    = []int{2, 1, 0}
+	starvationEvery = DefaultStarvationEvery
+	starvationMu    sync.RWMutex
+)
+
+// SetStarvationEvery 设置防饿死的频率：每拉取 n 次消息按低到高的顺序拉取一次
+// n 小于 0 时严格按优先级拉取，等于 0 时保持默认值
+func SetStarvationEvery(n int) {
+	starvationMu.Lock()
+	defer starvationMu.Unlock()
+	if n != 0 {
+		starvationEvery = n
+	}
+}
+
+// LaneScheduler 决定每次拉取消息时各优先级通道的顺序
+type LaneScheduler struct {
+	every uint64
+	n     uint64
+}
+
+// NewLaneScheduler 创建通道调度器，每个队列一个
+func NewLaneScheduler() *LaneScheduler {
+	starvationMu.RLock()
+	defer starvationMu.RUnlock()
+	s := &LaneScheduler{}
+	if starvationEvery > 0 {
+		s.every = uint64(starvationEvery)
+	}
+	return s
+}
+
+// Order 本次拉取时各通道的顺序，调用方不能修改返回的切片
+func (s *LaneScheduler) Order() []int {
+	n := atomic.AddUint64(&s.n, 1)
+	if s.every > 0 && n%s.every == 0 {
+		return reverseOrder
+	}
+	return laneOrder
+}
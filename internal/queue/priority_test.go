@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLane(t *testing.T) {
+	cases := []struct {
+		priority int
+		want     int
+	}{
+		{PriorityHigh, 0},
+		{5, 0},
+		{PriorityNormal, 1},
+		{PriorityLow, 2},
+		{-5, 2},
+	}
+	for _, c := range cases {
+		if got := Lane(c.priority); got != c.want {
+			t.Errorf("Lane(%d) = %d, want %d", c.priority, got, c.want)
+		}
+	}
+}
+
+// withStarvationEvery 临时修改防饿死频率，测试结束后恢复
+func withStarvationEvery(t *testing.T, n int) {
+	starvationMu.Lock()
+	old := starvationEvery
+	starvationEvery = n
+	starvationMu.Unlock()
+	t.Cleanup(func() {
+		starvationMu.Lock()
+		starvationEvery = old
+		starvationMu.Unlock()
+	})
+}
+
+func TestLaneSchedulerOrder(t *testing.T) {
+	cases := []struct {
+		name     string
+		every    int
+		calls    int
+		reversed []int // 按低到高顺序拉取的调用序号 (从 1 开始)
+	}{
+		{"every 3", 3, 9, []int{3, 6, 9}},
+		{"every 1 always reversed", 1, 3, []int{1, 2, 3}},
+		{"strict priority", -1, 20, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			withStarvationEvery(t, c.every)
+			s := NewLaneScheduler()
+			for i := 1; i <= c.calls; i++ {
+				want := []int{0, 1, 2}
+				if slices.Contains(c.reversed, i) {
+					want = []int{2, 1, 0}
+				}
+				if got := s.Order(); !slices.Equal(got, want) {
+					t.Errorf("call %d: Order() = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetStarvationEvery(t *testing.T) {
+	withStarvationEvery(t, DefaultStarvationEvery)
+	SetStarvationEvery(0)
+	if starvationEvery != DefaultStarvationEvery {
+		t.Errorf("SetStarvationEvery(0) changed the value to %d", starvationEvery)
+	}
+	SetStarvationEvery(5)
+	if starvationEvery != 5 {
+		t.Errorf("SetStarvationEvery(5) = %d", starvationEvery)
+	}
+}
@@ -2,23 +2,98 @@ package queue
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/hhr0815hhr/gint/internal"
 )
 
 // Message 定义队列中消息的结构
+// 信封字段 (CreatedAt 之后) 由驱动在发布时通过 Stamp 补全，旧版本发布的消息没有这些字段，SchemaVersion 为 0
 type Message struct {
+	Id        string // 消息唯一标识，发布时自动生成
 	Body      gin.H
 	MsgType   string //消息类型
 	ReInCount int    // 当前重试次数  大于一定次数放入死信队列
+	Priority  int    // 优先级，见 PriorityHigh / PriorityNormal / PriorityLow
+	// 幂等键，相同幂等键的消息只会被成功处理一次，为空时使用 Id
+	IdempotencyKey string
 	// 可以根据需要添加更丰富的元数据
 	Headers map[string]interface{}
+
+	CreatedAt     time.Time // 首次发布的时间
+	Producer      string    `json:",omitempty"` // 发布消息的服务
+	SchemaVersion int       `json:",omitempty"` // 信封版本，见 SchemaVersion
+	TraceId       string    `json:",omitempty"` // 链路追踪 Id，同一请求产生的消息相同
+	CorrelationId string    `json:",omitempty"` // 关联 Id，用于关联请求与响应等业务上相关的消息
+	Locale        string    `json:",omitempty"` // 发布时的语言
+	Tenant        string    `json:",omitempty"` // 租户
+	ReplyTo       string    `json:",omitempty"` // Request 发布的请求消息接收回复的广播 topic
+	RequestId     string    `json:",omitempty"` // Request 生成的请求 Id，回复带上相同的值，不占用 CorrelationId
+	Deadline      int64     `json:",omitempty"` // 请求的截止时间 (毫秒时间戳)，过期后不再处理
+}
+
+// Stamp 发布前补全消息的元数据，由各驱动在 Publish 时调用
+// 追踪信息、语言和租户优先使用消息上已有的值，其次使用 ctx 中的 Envelope (处理消息时发布的新消息会继承当前消息的信息)
+func (m *Message) Stamp(ctx context.Context) {
+	if m.Id == "" {
+		m.Id = NewId()
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	if m.Producer == "" {
+		m.Producer = Producer()
+	}
+	if m.SchemaVersion == 0 {
+		m.SchemaVersion = SchemaVersion
+	}
+	env, _ := EnvelopeFrom(ctx)
+	if m.TraceId == "" {
+		m.TraceId = env.TraceId
+	}
+	if m.TraceId == "" {
+		m.TraceId = NewId()
+	}
+	if m.CorrelationId == "" {
+		m.CorrelationId = env.CorrelationId
+	}
+	if m.Locale == "" {
+		m.Locale = env.Locale
+	}
+	if m.Locale == "" {
+		// gin.Context 中由 middleware.Locale 设置的语言
+		m.Locale, _ = ctx.Value("locale").(string)
+	}
+	if m.Tenant == "" {
+		m.Tenant = env.Tenant
+	}
+}
+
+// Expired 请求是否已经过了截止时间，请求方已经不再等待回复
+func (m *Message) Expired() bool {
+	return m.Deadline > 0 && time.Now().UnixMilli() > m.Deadline
+}
+
+// DedupKey 消费端去重使用的 key，按消息类型区分，不同类型的消息使用相同的幂等 key 互不影响
+func (m *Message) DedupKey() string {
+	key := m.Id
+	if m.IdempotencyKey != "" {
+		key = m.IdempotencyKey
+	}
+	if key == "" {
+		return ""
+	}
+	return m.MsgType + ":" + key
 }
 
 // Driver 队列驱动接口
 type Driver interface {
 	Publish(ctx context.Context, queueName string, message *Message) error
+	// PublishDelayed 延迟 delay 之后才可以被消费
+	PublishDelayed(ctx context.Context, queueName string, message *Message, delay time.Duration) error
+	// PublishAt 到达指定时间之后才可以被消费，at 早于当前时间时立即投递
+	PublishAt(ctx context.Context, queueName string, message *Message, at time.Time) error
 	Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *Message) error) error
 	// 可选：添加队列管理方法，例如创建队列、删除队列等
 	// EnsureQueueExists(ctx context.Context, queueName string) error
@@ -31,10 +106,53 @@ const (
 	DeadQueueName    = "dead_queue"
 )
 
+var (
+	defaultDriver   Driver
+	defaultDriverMu sync.RWMutex
+)
+
+// SetDriver 设置 PushQueue 等函数使用的队列驱动，应用初始化时与 App.Data["queue"] 一起设置
+// 不直接读取 internal.App，队列及驱动包可以脱离应用单独测试
+func SetDriver(d Driver) {
+	defaultDriverMu.Lock()
+	defer defaultDriverMu.Unlock()
+	defaultDriver = d
+}
+
+// driver 应用初始化时配置的队列驱动
+func driver() Driver {
+	defaultDriverMu.RLock()
+	defer defaultDriverMu.RUnlock()
+	return defaultDriver
+}
+
+// PushQueue 使用 context.Background() 发布消息，不会继承追踪信息，也不能取消
+// 在请求或消息处理中发布时使用 PushQueueContext
 func PushQueue(msg *Message, queueName string) error {
-	err := internal.App.Data["queue"].(Driver).Publish(context.Background(), queueName, msg)
-	if err != nil {
-		return err
-	}
-	return nil
+	return PushQueueContext(context.Background(), msg, queueName)
+}
+
+// PushQueueContext 发布消息，消息继承 ctx 中的追踪信息，ctx 取消时放弃发布
+func PushQueueContext(ctx context.Context, msg *Message, queueName string) error {
+	return driver().Publish(ctx, queueName, msg)
+}
+
+// PushQueueDelayed 延迟投递消息，例如"30分钟后取消未支付订单"，ctx 的限制同 PushQueue
+func PushQueueDelayed(msg *Message, queueName string, delay time.Duration) error {
+	return PushQueueDelayedContext(context.Background(), msg, queueName, delay)
+}
+
+// PushQueueDelayedContext 使用 ctx 延迟投递消息
+func PushQueueDelayedContext(ctx context.Context, msg *Message, queueName string, delay time.Duration) error {
+	return driver().PublishDelayed(ctx, queueName, msg, delay)
+}
+
+// PushQueueAt 在指定时间投递消息，ctx 的限制同 PushQueue
+func PushQueueAt(msg *Message, queueName string, at time.Time) error {
+	return PushQueueAtContext(context.Background(), msg, queueName, at)
+}
+
+// PushQueueAtContext 使用 ctx 在指定时间投递消息
+func PushQueueAtContext(ctx context.Context, msg *Message, queueName string, at time.Time) error {
+	return driver().PublishAt(ctx, queueName, msg, at)
 }
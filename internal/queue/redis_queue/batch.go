@@ -0,0 +1,196 @@
+package redis_queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+const batchPollInterval = 50 * time.Millisecond // 凑批时队列为空的轮询间隔
+
+var (
+	_ queue.BatchPublisher = (*RedisListDriver)(nil)
+	_ queue.BatchConsumer  = (*RedisListDriver)(nil)
+)
+
+// PublishBatch 在一个事务 pipeline 中写入所有消息，要么全部成功要么全部失败
+func (d *RedisListDriver) PublishBatch(ctx context.Context, queueName string, messages []*queue.Message) error {
+	payloads := make([][]byte, len(messages))
+	for i, message := range messages {
+		message.Stamp(ctx)
+		payloadJSON, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		payloads[i] = payloadJSON
+	}
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, message := range messages {
+			pipe.LPush(ctx, laneKey(queueName, message.Priority), payloads[i])
+		}
+		return nil
+	})
+	return err
+}
+
+// ConsumeBatch 批量消费，阻塞等待第一条消息，之后在 wait 时间内凑满 size 条，一批消息占用一个 worker
+// 可靠模式下 handler 返回后确认处理成功的消息 (见 queue.FailedIn)，失败的消息在可见性超时后重新投递
+func (d *RedisListDriver) ConsumeBatch(ctx context.Context, queueName string, size int, wait time.Duration, handler queue.BatchHandlerFunc) error {
+	if d.startOnce("delayed", queueName) {
+		go d.moveDelayed(ctx, queueName)
+	}
+	processing := processingKey(queueName, d.consumerId)
+	if d.reliable && d.startOnce("reliable", queueName) {
+		go d.reap(ctx, queueName)
+	}
+
+	pool := queue.Pool(queueName)
+	size = max(size, 1)
+	for {
+		if err := pool.Acquire(ctx); err != nil {
+			return nil
+		}
+		items, err := d.collect(ctx, queueName, processing, size, wait)
+		if len(items) == 0 {
+			pool.Release()
+			if ctx.Err() != nil {
+				return nil // 正常退出
+			}
+			if err != redis.Nil {
+				log.Logger.Printf("Error popping from Redis: %v\n", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		messages := make([]*queue.Message, 0, len(items))
+		decoded := make([]popped, 0, len(items))
+		for _, it := range items {
+			var payload = &queue.Message{}
+			if err = json.Unmarshal([]byte(it.payload), payload); err != nil {
+				// 可靠模式下放入死信队列之后才确认，失败时留在 processing 列表中，超时后重新投递
+				if derr := d.deadLetterRaw(ctx, queueName, it.payload, err); derr != nil {
+					log.Logger.Errorf("Move undecodable message of %s to %s failed: %v", queueName, queue.DeadQueueName, derr)
+					continue
+				}
+				d.ackAll(queueName, processing, it.payload)
+				continue
+			}
+			it.queueName = queueName
+			messages = append(messages, payload)
+			decoded = append(decoded, it)
+		}
+		if len(messages) == 0 {
+			pool.Release()
+			continue
+		}
+		pool.Go(func() {
+			err := handler(ctx, messages)
+			if errors.Is(err, queue.ErrAborted) {
+				// 整批交还给队列
+				for _, it := range decoded {
+					d.unfinished.Finish(it, err)
+				}
+				return
+			}
+			if err != nil {
+				log.Logger.Errorf("Handle %d messages from %s failed: %v", len(messages), queueName, err)
+			}
+			acked := make([]string, 0, len(messages))
+			for i, message := range messages {
+				if queue.FailedIn(err, message) == nil {
+					acked = append(acked, decoded[i].payload)
+				}
+			}
+			d.ackAll(queueName, processing, acked...)
+		})
+	}
+}
+
+// collect 阻塞取到第一条消息后，在 wait 时间内继续取消息直到凑满 size 条
+func (d *RedisListDriver) collect(ctx context.Context, queueName, processing string, size int, wait time.Duration) ([]popped, error) {
+	first, err := d.blockOne(ctx, queueName, processing)
+	if err != nil {
+		return nil, err
+	}
+	items := []popped{first}
+	deadline := time.Now().Add(wait)
+	for len(items) < size {
+		it, err := d.popOne(ctx, queueName, processing)
+		if err == nil {
+			items = append(items, it)
+			continue
+		}
+		if err != redis.Nil {
+			// 已经取出的消息先交给 handler 处理
+			log.Logger.Errorf("Error popping from Redis: %v", err)
+			break
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return items, nil
+		case <-time.After(min(remaining, batchPollInterval)):
+		}
+	}
+	return items, nil
+}
+
+// popOne 按通道顺序非阻塞地取一条消息，都为空时返回 redis.Nil
+func (d *RedisListDriver) popOne(ctx context.Context, queueName, processing string) (popped, error) {
+	if d.reliable {
+		payloadJSON, err := d.tryPopReliable(ctx, queueName, processing)
+		return popped{key: processing, payload: payloadJSON}, err
+	}
+	for _, key := range orderedKeys(laneKeys(queueName), d.scheduler(queueName).Order()) {
+		payloadJSON, err := d.client.LPop(ctx, key).Result()
+		if err != redis.Nil {
+			return popped{key: key, payload: payloadJSON}, err
+		}
+	}
+	return popped{}, redis.Nil
+}
+
+// blockOne 阻塞等待一条消息，超时返回 redis.Nil
+func (d *RedisListDriver) blockOne(ctx context.Context, queueName, processing string) (popped, error) {
+	if d.reliable {
+		payloadJSON, err := d.popReliable(ctx, queueName, processing)
+		return popped{key: processing, payload: payloadJSON}, err
+	}
+	keys := orderedKeys(laneKeys(queueName), d.scheduler(queueName).Order())
+	result, err := d.client.BLPop(ctx, reliableBlockTimeout, keys...).Result()
+	if err != nil {
+		return popped{}, err
+	}
+	if len(result) < 2 {
+		return popped{}, redis.Nil
+	}
+	return popped{key: result[0], payload: result[1]}, nil
+}
+
+// ackAll 确认多条消息处理完成
+func (d *RedisListDriver) ackAll(queueName, processing string, payloads ...string) {
+	if !d.reliable || len(payloads) == 0 {
+		return
+	}
+	// 不使用消费 ctx，避免退出时已处理完成的消息无法确认
+	ctx := context.Background()
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, payloadJSON := range payloads {
+			pipe.LRem(ctx, processing, 1, payloadJSON)
+			pipe.ZRem(ctx, inflightKey(queueName), inflightMember(processing, payloadJSON))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to ack messages of %s: %v", queueName, err)
+	}
+}
@@ -0,0 +1,73 @@
+package redis_queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+const broadcastPrefix = "queue:broadcast:"
+
+var _ queue.Broadcaster = (*Broadcaster)(nil)
+
+// Broadcaster 使用 Redis Pub/Sub 实现的广播，连接同一个 Redis 的所有进程都能收到消息
+type Broadcaster struct {
+	client redis.Cmdable
+}
+
+// subscriber *redis.Client 和 *redis.ClusterClient 都支持订阅
+type subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// NewBroadcaster 创建 Redis 广播
+func NewBroadcaster(client redis.Cmdable) *Broadcaster {
+	return &Broadcaster{client: client}
+}
+
+// Broadcast 发布到 topic 对应的 channel
+func (b *Broadcaster) Broadcast(ctx context.Context, topic string, message *queue.Message) error {
+	message.Stamp(ctx)
+	payloadJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return b.client.Publish(ctx, broadcastPrefix+topic, payloadJSON).Err()
+}
+
+// Subscribe 订阅 topic 对应的 channel，直到 ctx 取消或连接断开
+func (b *Broadcaster) Subscribe(ctx context.Context, topic string, handler queue.HandlerFunc) error {
+	s, ok := b.client.(subscriber)
+	if !ok {
+		return errors.New("redis client does not support subscribe")
+	}
+	pubsub := s.Subscribe(ctx, broadcastPrefix+topic)
+	defer pubsub.Close()
+	// 等待订阅确认，之后发布的消息都能收到
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			message := &queue.Message{}
+			if err := json.Unmarshal([]byte(msg.Payload), message); err != nil {
+				log.Logger.Errorf("Failed to unmarshal broadcast of topic %s: %v", topic, err)
+				continue
+			}
+			_ = handler(ctx, message)
+		}
+	}
+}
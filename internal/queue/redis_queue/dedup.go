@@ -0,0 +1,69 @@
+package redis_queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+const (
+	dedupPrefix = "queue:dedup:"
+
+	dedupProcessing = "processing"
+	dedupDone       = "done"
+)
+
+// claimScript 不存在时占用 key，已存在时返回当前的值; KEYS: key; ARGV: processing, ttl(毫秒)
+var claimScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return ""
+end
+return redis.call("GET", KEYS[1]) or ""
+`)
+
+// releaseScript 只删除处理中的 key，避免删掉已经处理成功的记录
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// DedupStore 基于 Redis SET NX 和 key 过期时间实现的去重存储
+type DedupStore struct {
+	client redis.Cmdable
+}
+
+var _ queue.DedupStore = (*DedupStore)(nil)
+
+// NewDedupStore 创建 Redis 去重存储
+func NewDedupStore(client redis.Cmdable) *DedupStore {
+	return &DedupStore{client: client}
+}
+
+// Claim 使用 SET NX 占用 key，key 已存在时按它的值区分处理中和已经处理成功
+func (s *DedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (queue.ClaimResult, error) {
+	value, err := claimScript.Run(ctx, s.client, []string{dedupPrefix + key}, dedupProcessing, ttl.Milliseconds()).Text()
+	if err != nil {
+		return queue.ClaimBusy, err
+	}
+	switch value {
+	case "":
+		return queue.ClaimOK, nil
+	case dedupDone:
+		return queue.ClaimDone, nil
+	}
+	return queue.ClaimBusy, nil
+}
+
+// Done 标记 key 对应的消息处理成功
+func (s *DedupStore) Done(ctx context.Context, key string, ttl time.Duration) error {
+	return s.client.Set(ctx, dedupPrefix+key, dedupDone, ttl).Err()
+}
+
+// Release 释放处理中的 key
+func (s *DedupStore) Release(ctx context.Context, key string) error {
+	return releaseScript.Run(ctx, s.client, []string{dedupPrefix + key}, dedupProcessing).Err()
+}
@@ -0,0 +1,73 @@
+package redis_queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// PublishDelayed 延迟投递，消息先写入有序集合，到期后由消费端的搬运任务放入队列
+func (d *RedisListDriver) PublishDelayed(ctx context.Context, queueName string, message *queue.Message, delay time.Duration) error {
+	return d.PublishAt(ctx, queueName, message, time.Now().Add(delay))
+}
+
+// PublishAt 在指定时间投递
+func (d *RedisListDriver) PublishAt(ctx context.Context, queueName string, message *queue.Message, at time.Time) error {
+	if !at.After(time.Now()) {
+		return d.Publish(ctx, queueName, message)
+	}
+	message.Stamp(ctx)
+	payloadJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	// member 加上随机前缀，避免内容相同的消息在有序集合中被合并
+	member := queue.NewId() + "|" + string(payloadJSON)
+	return d.client.ZAdd(ctx, delayedKey(queueName), &redis.Z{Score: float64(at.UnixMilli()), Member: member}).Err()
+}
+
+// moveScript 将到期的延迟消息搬运到对应优先级的通道
+// KEYS: delayed, 高/普通/低通道; ARGV: 当前毫秒时间戳, 数量上限
+var moveScript = redis.NewScript(laneLua + `
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, member in ipairs(items) do
+	redis.call('ZREM', KEYS[1], member)
+	local sep = string.find(member, '|', 1, true)
+	if sep then
+		local payload = string.sub(member, sep + 1)
+		redis.call('LPUSH', lane(payload, KEYS[2], KEYS[3], KEYS[4]), payload)
+	end
+end
+return #items
+`)
+
+// moveDelayed 定时搬运到期的延迟消息，脚本是原子的，多个进程同时搬运也不会重复投递
+func (d *RedisListDriver) moveDelayed(ctx context.Context, queueName string) {
+	keys := append([]string{delayedKey(queueName)}, laneKeys(queueName)...)
+	ticker := time.NewTicker(delayPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := moveScript.Run(ctx, d.client, keys, time.Now().UnixMilli(), delayBatchSize).Int()
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Logger.Errorf("Failed to move delayed messages of %s: %v", queueName, err)
+					}
+					break
+				}
+				if n < delayBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
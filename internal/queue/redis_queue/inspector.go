@@ -0,0 +1,106 @@
+package redis_queue
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// Len 队列中的消息数量 (不包含延迟消息和处理中的消息)
+func (d *RedisListDriver) Len(ctx context.Context, queueName string) (int64, error) {
+	var total int64
+	for _, key := range laneKeys(queueName) {
+		n, err := d.client.LLen(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// List 分页查看队列中的消息，按优先级从高到低、同一优先级按入队顺序排列
+func (d *RedisListDriver) List(ctx context.Context, queueName string, offset, limit int64) ([]*queue.Message, error) {
+	var msgs []*queue.Message
+	for _, key := range laneKeys(queueName) {
+		if limit <= 0 {
+			break
+		}
+		n, err := d.client.LLen(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if offset >= n {
+			offset -= n
+			continue
+		}
+		// LPUSH 入队，最早的消息在列表尾部
+		raws, err := d.client.LRange(ctx, key, -(offset + limit), -(offset + 1)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i := len(raws) - 1; i >= 0; i-- {
+			msgs = append(msgs, queue.DecodeStored(raws[i], ""))
+		}
+		limit -= int64(len(raws))
+		offset = 0
+	}
+	return msgs, nil
+}
+
+// Remove 删除指定 Id 的消息
+func (d *RedisListDriver) Remove(ctx context.Context, queueName, id string) error {
+	for _, key := range laneKeys(queueName) {
+		removed, err := d.removeFrom(ctx, key, id)
+		if err != nil || removed {
+			return err
+		}
+	}
+	return queue.ErrMessageNotFound
+}
+
+func (d *RedisListDriver) removeFrom(ctx context.Context, key, id string) (bool, error) {
+	for start := int64(0); ; start += inspectBatchSize {
+		raws, err := d.client.LRange(ctx, key, start, start+inspectBatchSize-1).Result()
+		if err != nil {
+			return false, err
+		}
+		for _, raw := range raws {
+			if queue.DecodeStored(raw, "").Id != id {
+				continue
+			}
+			n, err := d.client.LRem(ctx, key, 1, raw).Result()
+			if err != nil {
+				return false, err
+			}
+			if n > 0 {
+				return true, nil
+			}
+		}
+		if len(raws) < inspectBatchSize {
+			return false, nil
+		}
+	}
+}
+
+// Purge 清空队列的所有优先级通道
+func (d *RedisListDriver) Purge(ctx context.Context, queueName string) (int64, error) {
+	keys := laneKeys(queueName)
+	lens := make([]*redis.IntCmd, len(keys))
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			lens[i] = pipe.LLen(ctx, key)
+		}
+		pipe.Del(ctx, keys...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, n := range lens {
+		total += n.Val()
+	}
+	return total, nil
+}
@@ -5,62 +5,300 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/hhr0815hhr/gint/internal/config"
 	"github.com/hhr0815hhr/gint/internal/log"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/hhr0815hhr/gint/internal/queue" // 替换为你的模块路径
 )
 
+const (
+	defaultVisibilityTimeout = 30 * time.Second
+	defaultReapInterval      = 5 * time.Second
+	reliableBlockTimeout     = time.Second            // 阻塞读取的超时时间，便于及时响应 ctx 取消和其他优先级通道
+	reliablePollInterval     = 100 * time.Millisecond // 可靠模式下所有通道都为空时的轮询间隔
+	reapBatchSize            = 100
+	delayPollInterval        = time.Second // 延迟消息的搬运间隔
+	delayBatchSize           = 100
+	inspectBatchSize         = 100
+)
+
+var (
+	_ queue.Inspector = (*RedisListDriver)(nil)
+	_ queue.Requeuer  = (*RedisListDriver)(nil)
+)
+
 // RedisListDriver 使用 Redis List 实现的队列驱动
+// 每个队列按优先级分为高、普通、低三个 list，普通优先级沿用队列名作为 key
 type RedisListDriver struct {
 	client redis.Cmdable
 	Type   string
+
+	// 可靠投递模式：消息先移动到消费者自己的 processing 列表，处理成功后才删除，
+	// 超过可见性超时仍未确认的消息由 reaper 放回队列
+	reliable     bool
+	consumerId   string
+	visibility   time.Duration
+	reapInterval time.Duration
+
+	mu      sync.Mutex
+	started map[string]bool // 每个队列只需启动一次的后台任务
+	scheds  map[string]*queue.LaneScheduler
+
+	// 非可靠模式下已经取出还没有处理完成的消息，退出时放回队列
+	unfinished queue.Unfinished[popped]
+}
+
+// popped 从通道中取出的一条消息，可靠模式下 key 为 processing 列表
+type popped struct {
+	key       string
+	payload   string
+	queueName string
+}
+
+// Option RedisListDriver 的可选配置
+type Option func(d *RedisListDriver)
+
+// WithReliable 开启至少一次(at-least-once)的可靠投递模式
+func WithReliable(consumerId string, visibility, reapInterval time.Duration) Option {
+	return func(d *RedisListDriver) {
+		d.reliable = true
+		d.consumerId = consumerId
+		d.visibility = visibility
+		d.reapInterval = reapInterval
+	}
+}
+
+// ConfigOptions 根据 config.Conf.Queue 生成驱动配置
+func ConfigOptions() []Option {
+	conf := config.Conf.Queue
+	if !conf.Reliable {
+		return nil
+	}
+	return []Option{
+		WithReliable(
+			conf.ConsumerId,
+			time.Duration(conf.VisibilityTimeout)*time.Second,
+			time.Duration(conf.ReapInterval)*time.Second,
+		),
+	}
 }
 
 // NewRedisListDriver 创建一个新的 Redis List 驱动
-func NewRedisListDriver(client redis.Cmdable, t string) *RedisListDriver {
-	return &RedisListDriver{client: client, Type: t}
+func NewRedisListDriver(client redis.Cmdable, t string, opts ...Option) *RedisListDriver {
+	d := &RedisListDriver{
+		client:  client,
+		Type:    t,
+		started: make(map[string]bool),
+		scheds:  make(map[string]*queue.LaneScheduler),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.consumerId == "" {
+		// 每个进程使用自己的 processing 列表，同一主机上的多个进程不会共用
+		hostname, _ := os.Hostname()
+		d.consumerId = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if d.visibility <= 0 {
+		d.visibility = defaultVisibilityTimeout
+	}
+	if d.reapInterval <= 0 {
+		d.reapInterval = defaultReapInterval
+	}
+	return d
+}
+
+// laneKeys 各优先级通道的 key (高、普通、低)，使用 hash tag 保证 cluster 下在同一 slot，可以一次 BLPOP
+func laneKeys(queueName string) []string {
+	return []string{
+		fmt.Sprintf("{%s}:high", queueName),
+		queueName,
+		fmt.Sprintf("{%s}:low", queueName),
+	}
+}
+
+// laneKey 消息按优先级写入的 key
+func laneKey(queueName string, priority int) string {
+	return laneKeys(queueName)[queue.Lane(priority)]
 }
 
-// Publish 将消息发布到 Redis List
+// laneLua 在脚本中根据消息的 Priority 选择通道，参数依次为高、普通、低通道的 key
+const laneLua = `
+local function lane(payload, high, normal, low)
+	local ok, m = pcall(cjson.decode, payload)
+	if ok and type(m) == 'table' and type(m.Priority) == 'number' then
+		if m.Priority > 0 then
+			return high
+		elseif m.Priority < 0 then
+			return low
+		end
+	end
+	return normal
+end
+`
+
+// processingKey 消费者正在处理的消息列表，使用 hash tag 保证 cluster 下与队列在同一 slot
+func processingKey(queueName, consumerId string) string {
+	return fmt.Sprintf("{%s}:processing:%s", queueName, consumerId)
+}
+
+// inflightKey 记录处理中消息的截止时间 (score 为过期时间戳)
+func inflightKey(queueName string) string {
+	return fmt.Sprintf("{%s}:inflight", queueName)
+}
+
+func inflightMember(processing, payload string) string {
+	return processing + "\n" + payload
+}
+
+// delayedKey 延迟消息的有序集合 (score 为投递时间的毫秒时间戳)
+func delayedKey(queueName string) string {
+	return fmt.Sprintf("{%s}:delayed", queueName)
+}
+
+// startOnce 同一进程内的多个消费者共享一个后台任务，返回 false 表示已经启动过
+func (d *RedisListDriver) startOnce(task, queueName string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := task + ":" + queueName
+	if d.started[key] {
+		return false
+	}
+	d.started[key] = true
+	return true
+}
+
+// scheduler 同一队列的多个消费者共享通道调度器
+func (d *RedisListDriver) scheduler(queueName string) *queue.LaneScheduler {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s, ok := d.scheds[queueName]
+	if !ok {
+		s = queue.NewLaneScheduler()
+		d.scheds[queueName] = s
+	}
+	return s
+}
+
+// orderedKeys 按本次拉取的通道顺序排列 key
+func orderedKeys(keys []string, order []int) []string {
+	ordered := make([]string, len(order))
+	for i, lane := range order {
+		ordered[i] = keys[lane]
+	}
+	return ordered
+}
+
+// Publish 将消息按优先级发布到对应的 Redis List
 func (d *RedisListDriver) Publish(ctx context.Context, queueName string, message *queue.Message) error {
+	message.Stamp(ctx)
 	payloadJSON, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
-	_, err = d.client.LPush(ctx, queueName, payloadJSON).Result()
+	_, err = d.client.LPush(ctx, laneKey(queueName, message.Priority), payloadJSON).Result()
 	return err
 }
 
-// Consume 从 Redis List 消费消息
+// Consume 从 Redis List 消费消息，BLPOP 多个通道时按优先级顺序返回第一个非空通道的消息
 func (d *RedisListDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	if d.startOnce("delayed", queueName) {
+		go d.moveDelayed(ctx, queueName)
+	}
+	if d.reliable {
+		return d.consumeReliable(ctx, queueName, handler)
+	}
+	pool := queue.Pool(queueName)
+	keys := laneKeys(queueName)
+	sched := d.scheduler(queueName)
 	for {
-		result, err := d.client.BLPop(ctx, 0*time.Second, queueName).Result()
+		// 没有空闲 worker 时不再取消息
+		if err := pool.Acquire(ctx); err != nil {
+			return nil
+		}
+		// 带超时阻塞，ctx 取消后及时停止取消息
+		result, err := d.client.BLPop(ctx, reliableBlockTimeout, orderedKeys(keys, sched.Order())...).Result()
 		if err != nil {
-			if err != redis.Nil && err != context.Canceled {
+			pool.Release()
+			if ctx.Err() != nil {
+				return nil // 正常退出
+			}
+			if err != redis.Nil {
 				log.Logger.Printf("Error popping from Redis: %v\n", err)
 				time.Sleep(time.Second)
 			}
-			if err == context.Canceled {
-				return nil // 正常退出
-			}
 			continue
 		}
 		log.Logger.Printf("Received message from Redis: %v\n", result)
-		if len(result) > 1 {
-			payloadJSON := result[1]
-			var payload = &queue.Message{}
-			if err = json.Unmarshal([]byte(payloadJSON), payload); err != nil {
-				log.Logger.Printf("Failed to unmarshal message payload: %v\n", err)
-				continue
+		if len(result) < 2 {
+			pool.Release()
+			continue
+		}
+		payloadJSON := result[1]
+		var payload = &queue.Message{}
+		if err = json.Unmarshal([]byte(payloadJSON), payload); err != nil {
+			pool.Release()
+			// 已经取出的消息只能放入死信队列，死信队列也写入失败时消息丢失
+			if derr := d.deadLetterRaw(ctx, queueName, payloadJSON, err); derr != nil {
+				log.Logger.Errorf("Undecodable message from %s is lost: %v", queueName, derr)
 			}
-			go handler(ctx, payload)
+			continue
 		}
+		pool.Go(func() {
+			err := handler(ctx, payload)
+			if d.unfinished.Finish(popped{key: result[0], payload: payloadJSON}, err) {
+				return
+			}
+			if err != nil {
+				log.Logger.Errorf("Handle message from %s failed: %v", queueName, err)
+			}
+		})
 	}
 }
 
+// RequeueUnfinished 把 handler 被中断的消息放回队列，下次最先被取出
+// 仍在运行的 handler 取出的消息不会放回：可靠模式下留在 processing 列表中，可见性超时后由 reaper 放回队列；非可靠模式下随进程退出丢失
+func (d *RedisListDriver) RequeueUnfinished(ctx context.Context) (int, error) {
+	items := d.unfinished.Take()
+	if len(items) == 0 {
+		return 0, nil
+	}
+	if d.reliable {
+		total := 0
+		for _, it := range items {
+			n, err := d.requeue(ctx, it)
+			if err != nil {
+				return total, err
+			}
+			total += n
+		}
+		return total, nil
+	}
+
+	_, err := d.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, it := range items {
+			pipe.LPush(ctx, it.key, it.payload)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// deadLetterRaw 无法解析的消息重试也没有意义，原始内容放入死信队列
+func (d *RedisListDriver) deadLetterRaw(ctx context.Context, queueName, raw string, cause error) error {
+	log.Logger.Errorf("Failed to unmarshal message payload from %s, move to %s: %v", queueName, queue.DeadQueueName, cause)
+	// 不使用消费 ctx，避免退出时写入失败
+	return d.Publish(context.WithoutCancel(ctx), queue.DeadQueueName, queue.Undecodable(queueName, raw, cause))
+}
+
 // Close 关闭 Redis 连接
 func (d *RedisListDriver) Close() error {
 	if d.Type == "cluster" {
@@ -0,0 +1,175 @@
+package redis_queue
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+// consumeReliable 可靠模式消费：RPOPLPUSH 到 processing 列表，handler 返回 nil 后才确认删除
+// 其他进程 (包括崩溃退出的进程) 留在 processing 列表中的消息由 reaper 在可见性超时后放回队列
+func (d *RedisListDriver) consumeReliable(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	processing := processingKey(queueName, d.consumerId)
+	if d.startOnce("reliable", queueName) {
+		go d.reap(ctx, queueName)
+	}
+
+	pool := queue.Pool(queueName)
+	for {
+		// 没有空闲 worker 时不再取消息
+		if err := pool.Acquire(ctx); err != nil {
+			return nil
+		}
+		payloadJSON, err := d.popReliable(ctx, queueName, processing)
+		if err != nil {
+			pool.Release()
+			if err == redis.Nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil // 正常退出
+			}
+			log.Logger.Printf("Error popping from Redis: %v\n", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		var payload = &queue.Message{}
+		if err = json.Unmarshal([]byte(payloadJSON), payload); err != nil {
+			pool.Release()
+			// 放入死信队列之后才确认，失败时留在 processing 列表中，超时后重新投递
+			if derr := d.deadLetterRaw(ctx, queueName, payloadJSON, err); derr != nil {
+				log.Logger.Errorf("Move undecodable message of %s to %s failed: %v", queueName, queue.DeadQueueName, derr)
+				continue
+			}
+			d.ack(queueName, processing, payloadJSON)
+			continue
+		}
+		pool.Go(func() {
+			err := handler(ctx, payload)
+			if d.unfinished.Finish(popped{key: processing, payload: payloadJSON, queueName: queueName}, err) {
+				return // 留在 processing 列表中，由 RequeueUnfinished 放回队列
+			}
+			if err != nil {
+				log.Logger.Errorf("Handle message from %s failed, will be redelivered after %s: %v", queueName, d.visibility, err)
+				return
+			}
+			d.ack(queueName, processing, payloadJSON)
+		})
+	}
+}
+
+// popScript 按通道顺序取一条消息移动到 processing 列表，同时记录截止时间，进程在两步之间崩溃也不会丢失跟踪
+// KEYS: processing, inflight, 按调度顺序排列的通道; ARGV: 截止时间戳
+var popScript = redis.NewScript(`
+for i = 3, #KEYS do
+	local v = redis.call('RPOPLPUSH', KEYS[i], KEYS[1])
+	if v then
+		redis.call('ZADD', KEYS[2], ARGV[1], KEYS[1] .. '\n' .. v)
+		return v
+	end
+end
+return false
+`)
+
+// popReliable 按通道顺序取一条消息，都为空时等待 reliablePollInterval 后返回 redis.Nil
+// 取出和记录截止时间在同一个脚本中完成，脚本中不能使用阻塞命令，所以改为轮询
+func (d *RedisListDriver) popReliable(ctx context.Context, queueName, processing string) (string, error) {
+	payloadJSON, err := d.tryPopReliable(ctx, queueName, processing)
+	if err != redis.Nil {
+		return payloadJSON, err
+	}
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(reliablePollInterval):
+	}
+	return "", redis.Nil
+}
+
+// tryPopReliable 按通道顺序非阻塞地取一条消息，都为空时返回 redis.Nil
+func (d *RedisListDriver) tryPopReliable(ctx context.Context, queueName, processing string) (string, error) {
+	keys := append([]string{processing, inflightKey(queueName)}, orderedKeys(laneKeys(queueName), d.scheduler(queueName).Order())...)
+	deadline := time.Now().Add(d.visibility).Unix()
+	return popScript.Run(ctx, d.client, keys, deadline).Text()
+}
+
+// ack 确认消息处理完成，从 processing 列表和 inflight 集合中删除
+func (d *RedisListDriver) ack(queueName, processing, payloadJSON string) {
+	// 不使用消费 ctx，避免退出时已处理完成的消息无法确认
+	ctx := context.Background()
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, processing, 1, payloadJSON)
+		pipe.ZRem(ctx, inflightKey(queueName), inflightMember(processing, payloadJSON))
+		return nil
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to ack message of %s: %v", queueName, err)
+	}
+}
+
+// requeueScript 将 processing 列表中的一条消息放回对应优先级通道的头部
+// KEYS: processing, inflight, 高/普通/低通道; ARGV: 消息
+var requeueScript = redis.NewScript(laneLua + `
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('ZREM', KEYS[2], KEYS[1] .. '\n' .. ARGV[1])
+redis.call('RPUSH', lane(ARGV[1], KEYS[3], KEYS[4], KEYS[5]), ARGV[1])
+return 1
+`)
+
+// requeue 把 handler 被中断的消息从 processing 列表放回队列，已经被回收的消息不会重复放回
+func (d *RedisListDriver) requeue(ctx context.Context, it popped) (int, error) {
+	keys := append([]string{it.key, inflightKey(it.queueName)}, laneKeys(it.queueName)...)
+	return requeueScript.Run(ctx, d.client, keys, it.payload).Int()
+}
+
+// reapScript 将超过可见性超时仍未确认的消息放回对应通道的尾部，优先被再次消费
+// processing 列表的 key 从 member 中解析，没有在 KEYS 中声明：它和 inflight 使用相同的 {queue} hash tag (见 processingKey)，
+// cluster 下在同一个 slot，脚本可以访问
+// KEYS: inflight, 高/普通/低通道; ARGV: 当前时间戳, 数量上限
+var reapScript = redis.NewScript(laneLua + `
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+local n = 0
+for _, member in ipairs(items) do
+	redis.call('ZREM', KEYS[1], member)
+	local sep = string.find(member, '\n', 1, true)
+	if sep then
+		local processing = string.sub(member, 1, sep - 1)
+		local payload = string.sub(member, sep + 1)
+		if redis.call('LREM', processing, 1, payload) > 0 then
+			redis.call('RPUSH', lane(payload, KEYS[2], KEYS[3], KEYS[4]), payload)
+			n = n + 1
+		end
+	end
+end
+return n
+`)
+
+// reap 定时回收超时的处理中消息
+func (d *RedisListDriver) reap(ctx context.Context, queueName string) {
+	keys := append([]string{inflightKey(queueName)}, laneKeys(queueName)...)
+	ticker := time.NewTicker(d.reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := reapScript.Run(ctx, d.client, keys, time.Now().Unix(), reapBatchSize).Int()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Logger.Errorf("Failed to reap expired messages of %s: %v", queueName, err)
+				}
+				continue
+			}
+			if n > 0 {
+				log.Logger.Warnf("Requeued %d expired in-flight messages to %s", n, queueName)
+			}
+		}
+	}
+}
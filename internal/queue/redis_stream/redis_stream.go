@@ -0,0 +1,443 @@
+package redis_stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hhr0815hhr/gint/internal/config"
+	"github.com/hhr0815hhr/gint/internal/log"
+	"github.com/hhr0815hhr/gint/internal/queue"
+)
+
+const (
+	defaultGroup       = "gint"
+	defaultMinIdle     = 30 * time.Second
+	defaultReclaimTick = 5 * time.Second
+	blockTimeout       = 5 * time.Second // 阻塞读取的超时时间，便于及时响应 ctx 取消
+	reclaimBatchSize   = 100
+	payloadField       = "payload"
+	delayPollInterval  = time.Second // 延迟消息的搬运间隔
+	delayBatchSize     = 100
+	inspectBatchSize   = 100
+)
+
+var _ queue.Inspector = (*RedisStreamDriver)(nil)
+
+// RedisStreamDriver 使用 Redis Streams + 消费者组实现的队列驱动
+// 多个 gint consumer 进程共享同一个消费者组，消息处理成功后 XACK 并 XDEL，stream 中只保留未投递和未确认的消息，
+// 因此一个队列只能由一个消费者组消费；处理失败或进程崩溃的消息留在 PEL 中，超过 minIdle 后被重新认领
+// stream 驱动不区分 Message.Priority，所有消息按写入顺序消费
+type RedisStreamDriver struct {
+	client   redis.Cmdable
+	Type     string
+	group    string
+	consumer string
+	minIdle  time.Duration
+	reclaim  time.Duration
+	maxLen   int64
+
+	mu      sync.Mutex
+	started map[string]bool // 每个队列只需启动一次的后台任务
+}
+
+// Option RedisStreamDriver 的可选配置
+type Option func(d *RedisStreamDriver)
+
+// WithGroup 设置消费者组与消费者名称
+func WithGroup(group, consumer string) Option {
+	return func(d *RedisStreamDriver) {
+		d.group = group
+		d.consumer = consumer
+	}
+}
+
+// WithReclaim 设置消息空闲多久后被重新认领，以及认领检查的间隔
+func WithReclaim(minIdle, interval time.Duration) Option {
+	return func(d *RedisStreamDriver) {
+		d.minIdle = minIdle
+		d.reclaim = interval
+	}
+}
+
+// WithMaxLen 发布时按近似长度裁剪 stream，确认后的消息已经删除，这只是积压的上限，裁剪掉的消息不会再被处理
+func WithMaxLen(maxLen int64) Option {
+	return func(d *RedisStreamDriver) {
+		d.maxLen = maxLen
+	}
+}
+
+// ConfigOptions 根据 config.Conf.Queue 生成驱动配置
+func ConfigOptions() []Option {
+	conf := config.Conf.Queue
+	return []Option{
+		WithGroup(conf.Group, conf.ConsumerId),
+		WithReclaim(time.Duration(conf.VisibilityTimeout)*time.Second, time.Duration(conf.ReapInterval)*time.Second),
+		WithMaxLen(conf.StreamMaxLen),
+	}
+}
+
+// NewRedisStreamDriver 创建一个新的 Redis Stream 驱动，client 可以是单机或集群客户端
+func NewRedisStreamDriver(client redis.Cmdable, t string, opts ...Option) *RedisStreamDriver {
+	d := &RedisStreamDriver{client: client, Type: t, started: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.group == "" {
+		d.group = defaultGroup
+	}
+	if d.consumer == "" {
+		host, _ := os.Hostname()
+		d.consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if d.minIdle <= 0 {
+		d.minIdle = defaultMinIdle
+	}
+	if d.reclaim <= 0 {
+		d.reclaim = defaultReclaimTick
+	}
+	return d
+}
+
+// streamKey 队列对应的 stream key，使用 hash tag 保证 cluster 下相关 key 在同一 slot
+func streamKey(queueName string) string {
+	return fmt.Sprintf("{%s}:stream", queueName)
+}
+
+// delayedKey 延迟消息的有序集合 (score 为投递时间的毫秒时间戳)
+func delayedKey(queueName string) string {
+	return fmt.Sprintf("{%s}:delayed", queueName)
+}
+
+// startOnce 同一进程内的多个消费者共享一个后台任务，返回 false 表示已经启动过
+func (d *RedisStreamDriver) startOnce(task, queueName string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := task + ":" + queueName
+	if d.started[key] {
+		return false
+	}
+	d.started[key] = true
+	return true
+}
+
+// Publish 将消息 XADD 到 stream
+func (d *RedisStreamDriver) Publish(ctx context.Context, queueName string, message *queue.Message) error {
+	message.Stamp(ctx)
+	payloadJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	args := &redis.XAddArgs{
+		Stream: streamKey(queueName),
+		Values: map[string]interface{}{payloadField: payloadJSON},
+	}
+	if d.maxLen > 0 {
+		args.MaxLen = d.maxLen
+		args.Approx = true
+	}
+	return d.client.XAdd(ctx, args).Err()
+}
+
+// PublishDelayed 延迟投递，消息先写入有序集合，到期后由消费端的搬运任务 XADD 到 stream
+func (d *RedisStreamDriver) PublishDelayed(ctx context.Context, queueName string, message *queue.Message, delay time.Duration) error {
+	return d.PublishAt(ctx, queueName, message, time.Now().Add(delay))
+}
+
+// PublishAt 在指定时间投递
+func (d *RedisStreamDriver) PublishAt(ctx context.Context, queueName string, message *queue.Message, at time.Time) error {
+	if !at.After(time.Now()) {
+		return d.Publish(ctx, queueName, message)
+	}
+	message.Stamp(ctx)
+	payloadJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	// member 加上随机前缀，避免内容相同的消息在有序集合中被合并
+	member := queue.NewId() + "|" + string(payloadJSON)
+	return d.client.ZAdd(ctx, delayedKey(queueName), &redis.Z{Score: float64(at.UnixMilli()), Member: member}).Err()
+}
+
+// moveScript 将到期的延迟消息 XADD 到 stream，ARGV[3] 大于 0 时按近似长度裁剪
+var moveScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+local maxLen = tonumber(ARGV[3])
+for _, member in ipairs(items) do
+	redis.call('ZREM', KEYS[1], member)
+	local sep = string.find(member, '|', 1, true)
+	if sep then
+		local payload = string.sub(member, sep + 1)
+		if maxLen > 0 then
+			redis.call('XADD', KEYS[2], 'MAXLEN', '~', maxLen, '*', ARGV[4], payload)
+		else
+			redis.call('XADD', KEYS[2], '*', ARGV[4], payload)
+		end
+	end
+end
+return #items
+`)
+
+// moveDelayed 定时搬运到期的延迟消息，脚本是原子的，多个进程同时搬运也不会重复投递
+func (d *RedisStreamDriver) moveDelayed(ctx context.Context, queueName string) {
+	keys := []string{delayedKey(queueName), streamKey(queueName)}
+	ticker := time.NewTicker(delayPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := moveScript.Run(ctx, d.client, keys, time.Now().UnixMilli(), delayBatchSize, d.maxLen, payloadField).Int()
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Logger.Errorf("Failed to move delayed messages of %s: %v", queueName, err)
+					}
+					break
+				}
+				if n < delayBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Consume 以消费者组的方式消费 stream，handler 返回 nil 后 XACK
+func (d *RedisStreamDriver) Consume(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) error {
+	stream := streamKey(queueName)
+	if err := d.ensureGroup(ctx, stream); err != nil {
+		return err
+	}
+	if d.startOnce("delayed", queueName) {
+		go d.moveDelayed(ctx, queueName)
+	}
+	if d.startOnce("reclaim", queueName) {
+		go d.reclaimLoop(ctx, queueName, handler)
+	}
+
+	pool := queue.Pool(queueName)
+	for {
+		// 没有空闲 worker 时不再取消息
+		if err := pool.Acquire(ctx); err != nil {
+			return nil
+		}
+		streams, err := d.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    d.group,
+			Consumer: d.consumer,
+			Streams:  []string{stream, ">"},
+			Count:    1,
+			Block:    blockTimeout,
+		}).Result()
+		if err != nil {
+			pool.Release()
+			if err == redis.Nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil // 正常退出
+			}
+			log.Logger.Printf("Error reading from Redis stream: %v\n", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if len(streams) == 0 || len(streams[0].Messages) == 0 {
+			pool.Release()
+			continue
+		}
+		// Count 为 1，每次最多读取一条消息
+		d.dispatch(ctx, queueName, streams[0].Messages[0], pool, handler)
+	}
+}
+
+// ensureGroup 创建消费者组 (stream 不存在时一并创建)，组已存在时忽略
+func (d *RedisStreamDriver) ensureGroup(ctx context.Context, stream string) error {
+	err := d.client.XGroupCreateMkStream(ctx, stream, d.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s on %s: %w", d.group, stream, err)
+	}
+	return nil
+}
+
+// dispatch 在 pool 中处理一条消息，调用前需要已经 Acquire 了一个 worker
+func (d *RedisStreamDriver) dispatch(ctx context.Context, queueName string, msg redis.XMessage, pool *queue.WorkerPool, handler func(ctx context.Context, message *queue.Message) error) {
+	stream := streamKey(queueName)
+	raw, _ := msg.Values[payloadField].(string)
+	var payload = &queue.Message{}
+	if err := json.Unmarshal([]byte(raw), payload); err != nil {
+		// 无法解析的消息重试也没有意义，原始内容放入死信队列之后才确认，失败时留在 pending 中等待认领
+		pool.Release()
+		log.Logger.Errorf("Failed to unmarshal message payload %s from %s, move to %s: %v", msg.ID, queueName, queue.DeadQueueName, err)
+		dead := queue.Undecodable(queueName, raw, err)
+		if err = d.Publish(context.WithoutCancel(ctx), queue.DeadQueueName, dead); err != nil {
+			log.Logger.Errorf("Move undecodable message %s of %s to %s failed: %v", msg.ID, queueName, queue.DeadQueueName, err)
+			return
+		}
+		d.ack(stream, msg.ID)
+		return
+	}
+	pool.Go(func() {
+		if err := handler(ctx, payload); err != nil {
+			log.Logger.Errorf("Handle message %s from %s failed, will be reclaimed after %s: %v", msg.ID, stream, d.minIdle, err)
+			return
+		}
+		d.ack(stream, msg.ID)
+	})
+}
+
+// ack 确认消息并从 stream 中删除，避免已经处理完成的消息一直留在 stream 中
+func (d *RedisStreamDriver) ack(stream, id string) {
+	// 不使用消费 ctx，避免退出时已处理完成的消息无法确认
+	ctx := context.Background()
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, stream, d.group, id)
+		pipe.XDel(ctx, stream, id)
+		return nil
+	})
+	if err != nil {
+		log.Logger.Errorf("Failed to ack message %s of %s: %v", id, stream, err)
+	}
+}
+
+// reclaimLoop 定时认领空闲超过 minIdle 的 pending 消息并重新处理
+func (d *RedisStreamDriver) reclaimLoop(ctx context.Context, queueName string, handler func(ctx context.Context, message *queue.Message) error) {
+	stream := streamKey(queueName)
+	pool := queue.Pool(queueName)
+	ticker := time.NewTicker(d.reclaim)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			msgs, err := d.Reclaim(ctx, queueName)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Logger.Errorf("Failed to reclaim pending messages of %s: %v", stream, err)
+				}
+				continue
+			}
+			if len(msgs) > 0 {
+				log.Logger.Warnf("Reclaimed %d idle pending messages of %s", len(msgs), stream)
+			}
+			for _, msg := range msgs {
+				if err := pool.Acquire(ctx); err != nil {
+					return
+				}
+				d.dispatch(ctx, queueName, msg, pool, handler)
+			}
+		}
+	}
+}
+
+// Reclaim 将空闲超过 minIdle 的 pending 消息认领到当前消费者
+// 语义等同 XAUTOCLAIM，但 go-redis v8 无法解析 Redis 7 的 XAUTOCLAIM 返回值，这里用 XPENDING + XCLAIM 实现
+func (d *RedisStreamDriver) Reclaim(ctx context.Context, queueName string) ([]redis.XMessage, error) {
+	stream := streamKey(queueName)
+	pending, err := d.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  d.group,
+		Start:  "-",
+		End:    "+",
+		Count:  reclaimBatchSize,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(pending))
+	for _, p := range pending {
+		if p.Idle >= d.minIdle {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	// XCLAIM 会再次检查 MinIdle，多个消费者同时认领时只有一个成功
+	return d.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    d.group,
+		Consumer: d.consumer,
+		MinIdle:  d.minIdle,
+		Messages: ids,
+	}).Result()
+}
+
+// Pending 查看队列中已投递但未确认的消息
+func (d *RedisStreamDriver) Pending(ctx context.Context, queueName string, count int64) ([]redis.XPendingExt, error) {
+	return d.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: streamKey(queueName),
+		Group:  d.group,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+}
+
+// Len stream 中的消息数量，包含还没有投递和已投递但未确认的消息，确认后的消息已经删除，不计入
+func (d *RedisStreamDriver) Len(ctx context.Context, queueName string) (int64, error) {
+	return d.client.XLen(ctx, streamKey(queueName)).Result()
+}
+
+// List 分页查看 stream 中的消息，按入队顺序排列
+func (d *RedisStreamDriver) List(ctx context.Context, queueName string, offset, limit int64) ([]*queue.Message, error) {
+	entries, err := d.client.XRangeN(ctx, streamKey(queueName), "-", "+", offset+limit).Result()
+	if err != nil {
+		return nil, err
+	}
+	if offset >= int64(len(entries)) {
+		return nil, nil
+	}
+	msgs := make([]*queue.Message, 0, len(entries)-int(offset))
+	for _, entry := range entries[offset:] {
+		msgs = append(msgs, decodeEntry(entry))
+	}
+	return msgs, nil
+}
+
+// Remove 删除指定 Id 的消息
+func (d *RedisStreamDriver) Remove(ctx context.Context, queueName, id string) error {
+	stream := streamKey(queueName)
+	start := "-"
+	for {
+		entries, err := d.client.XRangeN(ctx, stream, start, "+", inspectBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if decodeEntry(entry).Id == id {
+				return d.client.XDel(ctx, stream, entry.ID).Err()
+			}
+		}
+		if len(entries) < inspectBatchSize {
+			return queue.ErrMessageNotFound
+		}
+		// 从最后一条的下一个 ID 继续遍历
+		start = "(" + entries[len(entries)-1].ID
+	}
+}
+
+// Purge 清空 stream，保留消费者组
+func (d *RedisStreamDriver) Purge(ctx context.Context, queueName string) (int64, error) {
+	return d.client.XTrimMaxLen(ctx, streamKey(queueName), 0).Result()
+}
+
+// decodeEntry 无法解析的消息以 entry ID 作为占位消息的 Id
+func decodeEntry(entry redis.XMessage) *queue.Message {
+	raw, _ := entry.Values[payloadField].(string)
+	return queue.DecodeStored(raw, entry.ID)
+}
+
+// Close 关闭 Redis 连接
+func (d *RedisStreamDriver) Close() error {
+	if d.Type == "cluster" {
+		return d.client.(*redis.ClusterClient).Close()
+	}
+	return d.client.(*redis.Client).Close()
+}
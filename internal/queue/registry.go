@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrUnknownMsgType = errors.New("unknown message type")
+
+// HandlerFunc 消息处理函数
+type HandlerFunc func(ctx context.Context, message *Message) error
+
+var (
+	handlers   = make(map[string]HandlerFunc)
+	handlersMu sync.RWMutex
+)
+
+// HandleMessage 注册消息类型的处理函数，需要读取 Headers 等元数据时使用
+func HandleMessage(msgType string, fn HandlerFunc) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if _, ok := handlers[msgType]; ok {
+		panic(fmt.Sprintf("queue: handler for message type %s already registered", msgType))
+	}
+	handlers[msgType] = fn
+}
+
+// Handle 注册消息类型的处理函数，Body 会以 JSON 解码为 T，解码失败的消息不会重试
+func Handle[T any](msgType string, fn func(ctx context.Context, body T) error) {
+	HandleMessage(msgType, func(ctx context.Context, message *Message) error {
+		body, err := Decode[T](message)
+		if err != nil {
+			return NonRetryable(fmt.Errorf("decode %s message body: %w", msgType, err))
+		}
+		return fn(ctx, body)
+	})
+}
+
+// Dispatch 按 MsgType 调用注册的处理函数，未注册的类型返回 ErrUnknownMsgType (不会重试)
+// Request 发布的消息在处理函数中可以通过 Reply 回复
+func Dispatch(ctx context.Context, message *Message) error {
+	handlersMu.RLock()
+	fn, ok := handlers[message.MsgType]
+	handlersMu.RUnlock()
+	if !ok {
+		return NonRetryable(fmt.Errorf("%w: %s", ErrUnknownMsgType, message.MsgType))
+	}
+	return fn(withRequest(ctx, message), message)
+}
+
+// MsgTypes 已注册的消息类型
+func MsgTypes() []string {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for t := range handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Decode 将消息 Body 解码为 T
+func Decode[T any](message *Message) (T, error) {
+	var body T
+	b, err := json.Marshal(message.Body)
+	if err != nil {
+		return body, err
+	}
+	err = json.Unmarshal(b, &body)
+	return body, err
+}
+
+// NewMessage 将 body 编码为消息，body 需要能以 JSON 对象表示
+func NewMessage[T any](msgType string, body T) (*Message, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	var h gin.H
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // 保留整数精度，避免大整数转成 float64
+	if err = dec.Decode(&h); err != nil {
+		return nil, fmt.Errorf("message body must be a JSON object: %w", err)
+	}
+	return &Message{MsgType: msgType, Body: h}, nil
+}
+
+// Publish 发布类型化的消息
+func Publish[T any](ctx context.Context, queueName, msgType string, body T) error {
+	message, err := NewMessage(msgType, body)
+	if err != nil {
+		return err
+	}
+	return driver().Publish(ctx, queueName, message)
+}
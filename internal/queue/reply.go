@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const HeaderReplyError = "x-reply-error" // 处理失败时回复中的错误信息
+
+var (
+	ErrRequestTimeout = errors.New("queue: request timed out")
+	ErrNoReplyTo      = errors.New("queue: message does not expect a reply")
+	// ErrRepliesNotStarted 当前进程没有调用 StartSubscribers，收不到回复
+	ErrRepliesNotStarted = errors.New("queue: reply subscription is not started")
+	// ErrRepliesLocal 广播只在进程内送达，而队列由多个进程共享，其他进程的消费者回复不到当前进程
+	ErrRepliesLocal = errors.New("queue: broadcaster is process-local, replies from other processes can not be delivered")
+)
+
+// RemoteError 消费者通过 ReplyError 回复的错误
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string { return e.Message }
+
+var (
+	// replyTopic 当前进程接收回复的广播 topic，每个进程不同
+	replyTopic = "reply." + NewId()
+	pending    = make(map[string]chan *Message)
+	pendingMu  sync.Mutex
+	// repliesStarted StartSubscribers 之后才能收到回复
+	repliesStarted atomic.Bool
+)
+
+func init() {
+	Subscribe(replyTopic, handleReply)
+}
+
+// handleReply 把回复交给等待中的 Request，请求已经超时的回复直接丢弃
+func handleReply(ctx context.Context, reply *Message) error {
+	pendingMu.Lock()
+	ch, ok := pending[reply.RequestId]
+	delete(pending, reply.RequestId)
+	pendingMu.Unlock()
+	if ok {
+		ch <- reply
+	}
+	return nil
+}
+
+// Request 发布请求消息并等待消费者通过 Reply 回复，timeout 内没有回复时返回 ErrRequestTimeout
+// 请求带上当前进程的回复地址、新的 RequestId 和截止时间，消费者不再处理已经过期的请求，CorrelationId 保持调用方的值
+// 回复通过广播送达，需要配置 Broadcaster 并在进程启动时调用 StartSubscribers
+// 广播只在进程内送达 (内存广播) 而队列由多个进程共享时返回 ErrRepliesLocal
+func Request(ctx context.Context, queueName string, message *Message, timeout time.Duration) (*Message, error) {
+	if !repliesStarted.Load() {
+		return nil, ErrRepliesNotStarted
+	}
+	if isLocal(currentBroadcaster()) && !isLocal(driver()) {
+		return nil, ErrRepliesLocal
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	message.ReplyTo = replyTopic
+	message.RequestId = NewId()
+	message.Deadline = deadline.UnixMilli()
+
+	ch := make(chan *Message, 1)
+	pendingMu.Lock()
+	pending[message.RequestId] = ch
+	pendingMu.Unlock()
+	defer func() {
+		pendingMu.Lock()
+		delete(pending, message.RequestId)
+		pendingMu.Unlock()
+	}()
+
+	if err := driver().Publish(ctx, queueName, message); err != nil {
+		return nil, err
+	}
+	select {
+	case reply := <-ch:
+		if msg, ok := reply.Headers[HeaderReplyError].(string); ok {
+			return reply, &RemoteError{Message: msg}
+		}
+		return reply, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s on %s after %s", ErrRequestTimeout, message.MsgType, queueName, timeout)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Call 发布类型化的请求，回复的 Body 解码为 R
+func Call[R any, T any](ctx context.Context, queueName, msgType string, body T, timeout time.Duration) (R, error) {
+	var resp R
+	message, err := NewMessage(msgType, body)
+	if err != nil {
+		return resp, err
+	}
+	reply, err := Request(ctx, queueName, message, timeout)
+	if err != nil {
+		return resp, err
+	}
+	return Decode[R](reply)
+}
+
+type requestKey struct{}
+
+// withRequest 处理 Request 发布的消息时记录请求，Reply 据此找到回复地址
+func withRequest(ctx context.Context, message *Message) context.Context {
+	if message.ReplyTo == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestKey{}, message)
+}
+
+// Reply 在处理函数中回复当前的请求，body 需要能以 JSON 对象表示
+// 当前消息不是 Request 发布的时返回 ErrNoReplyTo；批量处理函数不支持回复
+func Reply(ctx context.Context, body any) error {
+	request, ok := ctx.Value(requestKey{}).(*Message)
+	if !ok {
+		return ErrNoReplyTo
+	}
+	reply, err := NewMessage(request.MsgType, body)
+	if err != nil {
+		return err
+	}
+	return sendReply(ctx, request, reply)
+}
+
+// ReplyError 回复处理失败，请求方的 Request 返回 RemoteError
+func ReplyError(ctx context.Context, cause error) error {
+	request, ok := ctx.Value(requestKey{}).(*Message)
+	if !ok {
+		return ErrNoReplyTo
+	}
+	reply := &Message{
+		MsgType: request.MsgType,
+		Headers: map[string]interface{}{HeaderReplyError: cause.Error()},
+	}
+	return sendReply(ctx, request, reply)
+}
+
+func sendReply(ctx context.Context, request, reply *Message) error {
+	reply.RequestId = request.RequestId
+	reply.CorrelationId = request.CorrelationId
+	return Broadcast(ctx, request.ReplyTo, reply)
+}
@@ -0,0 +1,109 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hhr0815hhr/gint/internal/queue"
+	"github.com/hhr0815hhr/gint/internal/queue/memory_queue"
+)
+
+type echo struct {
+	Name string
+}
+
+// sharedDriver 包装内存驱动，模拟由多个进程共享的队列
+type sharedDriver struct {
+	queue.Driver
+}
+
+func init() {
+	queue.Handle("test.echo", func(ctx context.Context, body echo) error {
+		return queue.Reply(ctx, echo{Name: "hello " + body.Name})
+	})
+	queue.Handle("test.fail", func(ctx context.Context, body echo) error {
+		return queue.ReplyError(ctx, errors.New("bad request"))
+	})
+	queue.Handle("test.silent", func(ctx context.Context, body echo) error {
+		return nil
+	})
+}
+
+// startReplies 使用内存驱动和内存广播消费 queueName 并接收回复
+func startReplies(t *testing.T, queueName string) *memory_queue.InMemoryDriver {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	d := memory_queue.NewInMemoryDriver()
+	queue.SetDriver(d)
+	queue.SetBroadcaster(memory_queue.NewBroadcaster())
+	if err := queue.StartSubscribers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go d.Consume(ctx, queueName, queue.Dispatch)
+	// 等待回复的订阅生效
+	time.Sleep(50 * time.Millisecond)
+	return d
+}
+
+func TestRequestReply(t *testing.T) {
+	startReplies(t, "test.reply")
+	resp, err := queue.Call[echo](context.Background(), "test.reply", "test.echo", echo{Name: "gint"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "hello gint" {
+		t.Fatalf("reply = %q, want %q", resp.Name, "hello gint")
+	}
+}
+
+func TestRequestKeepsCorrelationId(t *testing.T) {
+	startReplies(t, "test.correlation")
+	message, err := queue.NewMessage("test.echo", echo{Name: "gint"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message.CorrelationId = "order-1"
+	reply, err := queue.Request(context.Background(), "test.correlation", message, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.CorrelationId != "order-1" || reply.CorrelationId != "order-1" {
+		t.Fatalf("correlation id = %q / %q, want order-1", message.CorrelationId, reply.CorrelationId)
+	}
+	if reply.RequestId == "" || reply.RequestId != message.RequestId {
+		t.Fatalf("reply request id = %q, want %q", reply.RequestId, message.RequestId)
+	}
+}
+
+func TestRequestRemoteError(t *testing.T) {
+	startReplies(t, "test.remote_error")
+	_, err := queue.Call[echo](context.Background(), "test.remote_error", "test.fail", echo{}, time.Second)
+	var remote *queue.RemoteError
+	if !errors.As(err, &remote) || remote.Message != "bad request" {
+		t.Fatalf("err = %v, want RemoteError(bad request)", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	startReplies(t, "test.timeout")
+	start := time.Now()
+	_, err := queue.Call[echo](context.Background(), "test.timeout", "test.silent", echo{}, 100*time.Millisecond)
+	if !errors.Is(err, queue.ErrRequestTimeout) {
+		t.Fatalf("err = %v, want ErrRequestTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timed out after %s", elapsed)
+	}
+}
+
+func TestRequestLocalBroadcaster(t *testing.T) {
+	d := startReplies(t, "test.local")
+	queue.SetDriver(sharedDriver{d})
+	_, err := queue.Call[echo](context.Background(), "test.local", "test.echo", echo{}, time.Second)
+	if !errors.Is(err, queue.ErrRepliesLocal) {
+		t.Fatalf("err = %v, want ErrRepliesLocal", err)
+	}
+}
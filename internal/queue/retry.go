@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 放入死信队列时记录在 Headers 中的信息
+const (
+	HeaderLastError     = "x-last-error"     // 最后一次处理失败的错误信息
+	HeaderFailedAt      = "x-failed-at"      // 放入死信队列的时间 (RFC3339)
+	HeaderOriginalQueue = "x-original-queue" // 消息原本所在的队列
+	HeaderRawPayload    = "x-raw-payload"    // 无法解析的消息的原始内容
+)
+
+// Undecodable 把无法解析的原始消息包装为死信消息，原始内容保存在 HeaderRawPayload 中，排查后可以手动修复
+func Undecodable(queueName, raw string, cause error) *Message {
+	return &Message{
+		Headers: map[string]interface{}{
+			HeaderRawPayload:    raw,
+			HeaderLastError:     cause.Error(),
+			HeaderFailedAt:      time.Now().Format(time.RFC3339),
+			HeaderOriginalQueue: queueName,
+		},
+	}
+}
+
+// RetryPolicy 消息处理失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts  int           // 最大处理次数 (包含第一次)，超过后放入死信队列
+	BaseDelay    time.Duration // 第一次重试的延迟，之后每次翻倍
+	MaxDelay     time.Duration // 重试延迟的上限
+	Jitter       float64       // 随机抖动比例 (0~1)，避免大量消息同时重试
+	NonRetryable []error       // 命中 (errors.Is) 这些错误时直接放入死信队列
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    10 * time.Minute,
+	Jitter:      0.2,
+}
+
+// Backoff 第 attempt 次重试 (从 0 开始) 前需要等待的时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	// 没有上限时翻倍到 math.MaxInt64 附近为止，避免溢出
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+// ShouldRetry 判断已经重试 reInCount 次的消息遇到 err 后是否还需要重试
+func (p RetryPolicy) ShouldRetry(reInCount int, err error) bool {
+	if IsNonRetryable(err) {
+		return false
+	}
+	for _, target := range p.NonRetryable {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return reInCount+1 < p.MaxAttempts
+}
+
+type nonRetryableError struct {
+	err error
+}
+
+func (e *nonRetryableError) Error() string { return e.err.Error() }
+func (e *nonRetryableError) Unwrap() error { return e.err }
+
+// NonRetryable 包装 handler 返回的错误，表示重试也无法成功，直接放入死信队列
+func NonRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &nonRetryableError{err: err}
+}
+
+// IsNonRetryable 错误是否被 NonRetryable 包装过
+func IsNonRetryable(err error) bool {
+	var target *nonRetryableError
+	return errors.As(err, &target)
+}
+
+var (
+	retryPolicies = make(map[string]RetryPolicy)
+	retryMu       sync.RWMutex
+)
+
+// SetRetryPolicy 设置某个消息类型的重试策略
+func SetRetryPolicy(msgType string, policy RetryPolicy) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryPolicies[msgType] = policy
+}
+
+// SetDefaultRetryPolicy 设置未单独配置的消息类型的重试策略
+func SetDefaultRetryPolicy(policy RetryPolicy) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	DefaultRetryPolicy = policy
+}
+
+// RetryPolicyFor 获取消息类型对应的重试策略
+func RetryPolicyFor(msgType string) RetryPolicy {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	if p, ok := retryPolicies[msgType]; ok {
+		return p
+	}
+	return DefaultRetryPolicy
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"first retry", RetryPolicy{BaseDelay: time.Second}, 0, time.Second},
+		{"doubles", RetryPolicy{BaseDelay: time.Second}, 3, 8 * time.Second},
+		{"capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, 3, 5 * time.Second},
+		{"large attempt stays capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 100, time.Minute},
+		{"base above cap", RetryPolicy{BaseDelay: time.Hour, MaxDelay: time.Minute}, 0, time.Minute},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.policy.Backoff(c.attempt); got != c.want {
+				t.Errorf("Backoff(%d) = %s, want %s", c.attempt, got, c.want)
+			}
+		})
+	}
+}
+
+func TestBackoffNoOverflow(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second}
+	prev := time.Duration(0)
+	for attempt := 0; attempt < 100; attempt++ {
+		got := policy.Backoff(attempt)
+		if got < prev {
+			t.Fatalf("Backoff(%d) = %s, less than Backoff(%d) = %s", attempt, got, attempt-1, prev)
+		}
+		prev = got
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	cases := []struct {
+		policy   RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{RetryPolicy{BaseDelay: time.Second, Jitter: 0.2}, 0, 800 * time.Millisecond, 1200 * time.Millisecond},
+		{RetryPolicy{BaseDelay: time.Second, MaxDelay: 4 * time.Second, Jitter: 0.5}, 5, 2 * time.Second, 6 * time.Second},
+		{RetryPolicy{BaseDelay: time.Second, Jitter: 1}, 1, 0, 4 * time.Second},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("jitter %.1f attempt %d", c.policy.Jitter, c.attempt), func(t *testing.T) {
+			for i := 0; i < 1000; i++ {
+				got := c.policy.Backoff(c.attempt)
+				if got < c.min || got > c.max {
+					t.Fatalf("Backoff(%d) = %s, want within [%s, %s]", c.attempt, got, c.min, c.max)
+				}
+			}
+		})
+	}
+}
+
+var errPermanent = errors.New("permanent")
+
+func TestShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, NonRetryable: []error{errPermanent}}
+	transient := errors.New("transient")
+	cases := []struct {
+		name      string
+		reInCount int
+		err       error
+		want      bool
+	}{
+		{"first failure", 0, transient, true},
+		{"second failure", 1, transient, true},
+		{"max attempts reached", 2, transient, false},
+		{"beyond max attempts", 5, transient, false},
+		{"NonRetryable wrapper", 0, NonRetryable(transient), false},
+		{"wrapped NonRetryable", 0, fmt.Errorf("handle: %w", NonRetryable(transient)), false},
+		{"policy error", 0, errPermanent, false},
+		{"wrapped policy error", 0, fmt.Errorf("handle: %w", errPermanent), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := policy.ShouldRetry(c.reInCount, c.err); got != c.want {
+				t.Errorf("ShouldRetry(%d, %v) = %v, want %v", c.reInCount, c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestNonRetryable(t *testing.T) {
+	cause := errors.New("bad input")
+	err := NonRetryable(cause)
+	if !IsNonRetryable(err) || !errors.Is(err, cause) || err.Error() != cause.Error() {
+		t.Errorf("NonRetryable(%v) = %v, want a non-retryable error wrapping the cause", cause, err)
+	}
+	if NonRetryable(nil) != nil {
+		t.Error("NonRetryable(nil) should be nil")
+	}
+	if IsNonRetryable(cause) {
+		t.Error("plain error should be retryable")
+	}
+}
+
+func TestRetryPolicyFor(t *testing.T) {
+	custom := RetryPolicy{MaxAttempts: 1}
+	SetRetryPolicy("retry_test_custom", custom)
+	if got := RetryPolicyFor("retry_test_custom"); got.MaxAttempts != custom.MaxAttempts {
+		t.Errorf("RetryPolicyFor(custom) = %+v, want %+v", got, custom)
+	}
+	if got := RetryPolicyFor("retry_test_other"); got.MaxAttempts != DefaultRetryPolicy.MaxAttempts {
+		t.Errorf("RetryPolicyFor(other) = %+v, want default", got)
+	}
+}
@@ -17,16 +17,19 @@ import (
 type AppInfo struct {
 	Engine    *gin.Engine
 	TestLogic *logic.TestLogic
+	CronLogic *logic.CronLogic
 	Data      map[string]interface{}
 }
 
 func ProvideApp(
 	engine *gin.Engine,
 	testLogic *logic.TestLogic,
+	cronLogic *logic.CronLogic,
 ) *AppInfo {
 	return &AppInfo{
 		Engine:    engine,
 		TestLogic: testLogic,
+		CronLogic: cronLogic,
 		Data:      map[string]interface{}{},
 	}
 }
@@ -40,21 +43,31 @@ var DbSet = wire.NewSet(mysql.ProvideDB)
 
 var RepoSet = wire.NewSet(
 	model.NewTestRepo,
+	model.NewCronRunRepo,
+	model.NewCronSettingRepo,
 )
 var LogicSet = wire.NewSet(
 	logic.NewTestLogic,
+	logic.NewCronLogic,
+	logic.NewQueueLogic,
 )
 
 var RouteSet = wire.NewSet(
 	controller.NewTestController,
+	controller.NewCronController,
+	controller.NewQueueController,
 )
 
 func ProvideRoutes(
 	test *controller.TestController,
+	cron *controller.CronController,
+	queue *controller.QueueController,
 ) *http.HTTPRoutes {
 	return &http.HTTPRoutes{
 		Routers: []controller.Router{
 			test,
+			cron,
+			queue,
 		},
 	}
 }
@@ -20,12 +20,18 @@ import (
 
 func InitApp() *AppInfo {
 	testController := controller.NewTestController()
-	httpRoutes := ProvideRoutes(testController)
-	engine := http.NewHTTPServer(httpRoutes)
 	db := mysql.ProvideDB()
+	cronRunRepo := model.NewCronRunRepo(db)
+	cronSettingRepo := model.NewCronSettingRepo(db)
+	cronLogic := logic.NewCronLogic(cronRunRepo, cronSettingRepo)
+	cronController := controller.NewCronController(cronLogic)
+	queueLogic := logic.NewQueueLogic()
+	queueController := controller.NewQueueController(queueLogic)
+	httpRoutes := ProvideRoutes(testController, cronController, queueController)
+	engine := http.NewHTTPServer(httpRoutes)
 	testRepo := model.NewTestRepo(db)
 	testLogic := logic.NewTestLogic(testRepo)
-	appInfo := ProvideApp(engine, testLogic)
+	appInfo := ProvideApp(engine, testLogic, cronLogic)
 	return appInfo
 }
 
@@ -34,34 +40,41 @@ func InitApp() *AppInfo {
 type AppInfo struct {
 	Engine    *gin.Engine
 	TestLogic *logic.TestLogic
+	CronLogic *logic.CronLogic
 	Data      map[string]interface{}
 }
 
 func ProvideApp(
 	engine *gin.Engine,
 	testLogic *logic.TestLogic,
+	cronLogic *logic.CronLogic,
 ) *AppInfo {
 	return &AppInfo{
 		Engine:    engine,
 		TestLogic: testLogic,
+		CronLogic: cronLogic,
 		Data:      map[string]interface{}{},
 	}
 }
 
 var DbSet = wire.NewSet(mysql.ProvideDB)
 
-var RepoSet = wire.NewSet(model.NewTestRepo)
+var RepoSet = wire.NewSet(model.NewTestRepo, model.NewCronRunRepo, model.NewCronSettingRepo)
 
-var LogicSet = wire.NewSet(logic.NewTestLogic)
+var LogicSet = wire.NewSet(logic.NewTestLogic, logic.NewCronLogic, logic.NewQueueLogic)
 
-var RouteSet = wire.NewSet(controller.NewTestController)
+var RouteSet = wire.NewSet(controller.NewTestController, controller.NewCronController, controller.NewQueueController)
 
 func ProvideRoutes(
 	test *controller.TestController,
+	cron *controller.CronController,
+	queue *controller.QueueController,
 ) *http.HTTPRoutes {
 	return &http.HTTPRoutes{
 		Routers: []controller.Router{
 			test,
+			cron,
+			queue,
 		},
 	}
 }